@@ -0,0 +1,368 @@
+package portaudio
+
+import (
+	"reflect"
+	"unsafe"
+
+	"github.com/gordonklaus/portaudio/resample"
+)
+
+// A streamAdapter sits between PortAudio and a StreamCallback whose Buffers differ from
+// the stream's native buffers in sample type, number of channels, or sample rate.
+// See StreamParameters.Resample and OpenNegotiatedStream.
+//
+// PortAudio's buffers are interleaved.  Samples are converted to float32, remapped,
+// and resampled on input, and the reverse on output.  If the callback's sample type
+// is the native one and the rates are equal, samples are only remapped, without
+// conversion, so that they pass through unchanged.  When resampling, the callback is
+// called with varying numbers of frames, as many as are needed to fill the output buffer
+// or as are available from the input buffer.
+type streamAdapter struct {
+	in, out *adapterPath
+}
+
+// An adapterPath adapts one direction of a stream.
+type adapterPath struct {
+	nativeChannels, userChannels int
+	nativeToFloat32              func(dst []float32, src unsafe.Pointer)
+	float32ToNative              func(dst unsafe.Pointer, src []float32)
+	res                          *resample.Resampler // nil if the rates are equal
+	native                       []float32           // samples converted to or from the native sample type
+	mapped                       []float32           // frames at the native rate with the callback's channels
+	frames                       []float32           // frames at the callback's rate, interleaved
+	planar                       []float32           // the callback's frames, in the layout of its Buffer
+	planarChannels               [][]float32         // the channels of planar, if the Buffer is non-interleaved
+	toUser                       func(dst unsafe.Pointer, src []float32)
+	fromUser                     func(dst []float32, src unsafe.Pointer)
+	user                         reflect.Value // the callback's frames, if not float32
+	elemSize                     uintptr
+	nonInterleaved               bool
+	buf                          *reflect.SliceHeader // the callback's Buffer
+
+	// fromNative and toNative, if not nil, remap samples between PortAudio's Buffer
+	// and the callback's Buffer without converting them, in place of the float32 conversions.
+	fromNative, toNative func(dst, src unsafe.Pointer, frames int)
+}
+
+// newAdapterPath returns an adapterPath between the callback's (addressable) Buffer buf
+// and the native sample type, each with the given number of channels.
+// The ratio is that of the path's output to its input sample rate.
+func newAdapterPath(buf reflect.Value, userChannels int, native reflect.Type, nativeChannels int, ratio float64, q resample.Quality) *adapterPath {
+	elem := buf.Type().Elem()
+	p := &adapterPath{
+		nativeChannels: nativeChannels,
+		userChannels:   userChannels,
+		nonInterleaved: elem.Kind() == reflect.Slice,
+		buf:            header(buf),
+	}
+	if p.nonInterleaved {
+		elem = elem.Elem()
+		p.planarChannels = make([][]float32, userChannels)
+	}
+	p.elemSize = elem.Size()
+	p.nativeToFloat32, p.float32ToNative = float32Funcs(native)
+	p.fromUser, p.toUser = float32Funcs(elem)
+	if p.toUser != nil {
+		p.user = reflect.MakeSlice(reflect.SliceOf(elem), 0, 0)
+	}
+	if ratio != 1 {
+		p.res = resample.New(userChannels, ratio, q)
+	} else if elem == native && p.toUser != nil {
+		p.fromNative, p.toNative = nativeRemapFuncs(elem, nativeChannels, userChannels, p.nonInterleaved)
+	}
+	return p
+}
+
+// callback adapts the native buffers, calls the stream's callback, and adapts its output.
+// It returns the number of frames passed to the callback.
+func (a *streamAdapter) callback(s *Stream, inputBuffer, outputBuffer unsafe.Pointer, frames int) int {
+	in, out := a.in, a.out
+	if inputBuffer == nil {
+		in = nil
+	}
+	if outputBuffer == nil {
+		out = nil
+	}
+	n := 0
+	if in != nil {
+		n = in.input(inputBuffer, frames)
+	}
+	if out != nil {
+		n = frames
+		if out.res != nil {
+			n = out.res.InputFrames(frames)
+		}
+		if in != nil {
+			in.pad(n)
+		}
+	}
+	if n > 0 {
+		if in != nil {
+			in.setInput(n)
+		}
+		if out != nil {
+			out.setOutput(n)
+		}
+		s.callback.Call(s.args)
+	}
+	if out != nil {
+		out.output(outputBuffer, frames, n)
+	}
+	if in != nil {
+		in.consume(n)
+	}
+	return n
+}
+
+// input converts, remaps, and resamples native input, appending it to p.frames,
+// and returns the number of frames available to the callback.
+func (p *adapterPath) input(buf unsafe.Pointer, frames int) int {
+	if p.fromNative != nil {
+		p.fromNative(p.userBuffer(frames), buf, frames)
+		return frames
+	}
+	var x []float32
+	if p.nativeToFloat32 == nil {
+		x = unsafe.Slice((*float32)(buf), frames*p.nativeChannels)
+	} else {
+		p.native = resize(p.native, frames*p.nativeChannels)
+		p.nativeToFloat32(p.native, buf)
+		x = p.native
+	}
+	if p.nativeChannels != p.userChannels {
+		p.mapped = resize(p.mapped, frames*p.userChannels)
+		remap(p.mapped, p.userChannels, x, p.nativeChannels)
+		x = p.mapped
+	}
+	have := len(p.frames)
+	if p.res == nil {
+		p.frames = grow(p.frames, have+len(x))
+		copy(p.frames[have:], x)
+	} else {
+		p.frames = grow(p.frames, have+p.res.OutputFrames(frames)*p.userChannels)
+		_, w := p.res.Process(p.frames[have:], x)
+		p.frames = p.frames[:have+w*p.userChannels]
+	}
+	return len(p.frames) / p.userChannels
+}
+
+// consume discards the first n frames of p.frames, which have been passed to the callback.
+func (p *adapterPath) consume(n int) {
+	if p.fromNative == nil {
+		p.frames = p.frames[:copy(p.frames, p.frames[n*p.userChannels:])]
+	}
+}
+
+// pad prepends silence to p.frames so that it has at least n frames,
+// which happens only at startup, while the resamplers fill.
+func (p *adapterPath) pad(n int) {
+	if p.fromNative != nil {
+		return
+	}
+	ch := p.userChannels
+	if have := len(p.frames) / ch; have < n {
+		p.frames = grow(p.frames, n*ch)
+		copy(p.frames[(n-have)*ch:], p.frames[:have*ch])
+		zero(p.frames[:(n-have)*ch])
+	}
+}
+
+// setInput sets the callback's Buffer to the first n frames of p.frames.
+func (p *adapterPath) setInput(n int) {
+	if p.fromNative != nil {
+		p.point(unsafe.Pointer(p.user.Pointer()), n)
+		return
+	}
+	x := p.frames[:n*p.userChannels]
+	if p.nonInterleaved {
+		p.planar = resize(p.planar, len(x))
+		Deinterleave(p.channels(n), x)
+		x = p.planar
+	}
+	data := p.setBuffer(x, n)
+	if p.toUser != nil {
+		p.toUser(data, x)
+	}
+}
+
+// setOutput sets the callback's Buffer to n frames.
+func (p *adapterPath) setOutput(n int) {
+	if p.toNative != nil {
+		p.point(p.userBuffer(n), n)
+		return
+	}
+	p.planar = resize(p.planar, n*p.userChannels)
+	p.setBuffer(p.planar, n)
+}
+
+// setBuffer points the callback's Buffer at n frames, either x or
+// (if the Buffer is not float32) p.user, and returns a pointer to them.
+func (p *adapterPath) setBuffer(x []float32, n int) unsafe.Pointer {
+	data := unsafe.Pointer(&x[0])
+	if p.toUser != nil {
+		data = p.userBuffer(n)
+	}
+	p.point(data, n)
+	return data
+}
+
+// userBuffer returns a pointer to p.user, grown if necessary to hold n frames.
+func (p *adapterPath) userBuffer(n int) unsafe.Pointer {
+	if size := n * p.userChannels; p.user.Cap() < size {
+		p.user = reflect.MakeSlice(p.user.Type(), size, size)
+	}
+	return unsafe.Pointer(p.user.Pointer())
+}
+
+// point points the callback's Buffer at the n frames at data.
+func (p *adapterPath) point(data unsafe.Pointer, n int) {
+	if p.nonInterleaved {
+		for c := 0; c < p.buf.Len; c++ {
+			h := unsafe.Add(unsafe.Pointer(p.buf.Data), c*int(unsafe.Sizeof(reflect.SliceHeader{})))
+			setSlice((*reflect.SliceHeader)(h), uintptr(unsafe.Add(data, c*n*int(p.elemSize))), n)
+		}
+	} else {
+		setSlice(p.buf, uintptr(data), n*p.userChannels)
+	}
+}
+
+// channels returns the channels of the n frames in p.planar.
+func (p *adapterPath) channels(n int) [][]float32 {
+	for c := range p.planarChannels {
+		p.planarChannels[c] = p.planar[c*n : (c+1)*n]
+	}
+	return p.planarChannels
+}
+
+// output resamples, remaps, and converts the n frames of the callback's Buffer
+// into the given number of native output frames.
+func (p *adapterPath) output(buf unsafe.Pointer, frames, n int) {
+	if p.toNative != nil {
+		p.toNative(buf, unsafe.Pointer(p.user.Pointer()), frames)
+		return
+	}
+	ch := p.userChannels
+	x := p.planar[:n*ch]
+	if p.fromUser != nil && n > 0 {
+		p.fromUser(x, unsafe.Pointer(p.user.Pointer()))
+	}
+	if p.nonInterleaved {
+		p.frames = resize(p.frames, len(x))
+		Interleave(p.frames, p.channels(n))
+		x = p.frames
+	}
+	if p.res != nil {
+		p.mapped = resize(p.mapped, frames*ch)
+		_, w := p.res.Process(p.mapped, x)
+		zero(p.mapped[w*ch:])
+		x = p.mapped
+	}
+	var dst []float32
+	if p.float32ToNative == nil {
+		dst = unsafe.Slice((*float32)(buf), frames*p.nativeChannels)
+	} else {
+		p.native = resize(p.native, frames*p.nativeChannels)
+		dst = p.native
+	}
+	if p.nativeChannels != ch {
+		remap(dst, p.nativeChannels, x, ch)
+	} else {
+		copy(dst, x)
+	}
+	if p.float32ToNative != nil {
+		p.float32ToNative(buf, dst)
+	}
+}
+
+// remap copies the interleaved frames of src, which has srcChannels channels, to dst, which has dstChannels.
+// A mono source is copied to every channel and a mono destination receives the average of all channels;
+// otherwise channels are copied by index and extra destination channels are silent.
+func remap(dst []float32, dstChannels int, src []float32, srcChannels int) {
+	frames := len(src) / srcChannels
+	switch {
+	case srcChannels == 1:
+		for i, x := range src[:frames] {
+			for c := 0; c < dstChannels; c++ {
+				dst[i*dstChannels+c] = x
+			}
+		}
+	case dstChannels == 1:
+		for i := range dst[:frames] {
+			var sum float32
+			for _, x := range src[i*srcChannels : (i+1)*srcChannels] {
+				sum += x
+			}
+			dst[i] = sum / float32(srcChannels)
+		}
+	default:
+		for i := 0; i < frames; i++ {
+			d, s := dst[i*dstChannels:(i+1)*dstChannels], src[i*srcChannels:(i+1)*srcChannels]
+			zero(d[copy(d, s):])
+		}
+	}
+}
+
+// nativeRemapFuncs returns functions that remap frames from the interleaved native buffer, with nativeChannels,
+// to the callback's buffer, with userChannels, and back, as remap does, for samples of type t, which is
+// a native integer sample type.  Samples are copied exactly; only those averaged into a mono destination change.
+func nativeRemapFuncs(t reflect.Type, nativeChannels, userChannels int, planar bool) (fromNative, toNative func(dst, src unsafe.Pointer, frames int)) {
+	if t == reflect.TypeOf(Int24{}) {
+		return remapFuncs(nativeChannels, userChannels, planar, func(x Int24) int64 { return int64(x.Int32() >> 8) }, func(x int64) (y Int24) {
+			y.PutInt32(int32(x) << 8)
+			return y
+		})
+	}
+	switch t.Kind() {
+	case reflect.Int32:
+		return remapFuncs(nativeChannels, userChannels, planar, func(x int32) int64 { return int64(x) }, func(x int64) int32 { return int32(x) })
+	case reflect.Int16:
+		return remapFuncs(nativeChannels, userChannels, planar, func(x int16) int64 { return int64(x) }, func(x int64) int16 { return int16(x) })
+	case reflect.Int8:
+		return remapFuncs(nativeChannels, userChannels, planar, func(x int8) int64 { return int64(x) }, func(x int64) int8 { return int8(x) })
+	case reflect.Uint8:
+		return remapFuncs(nativeChannels, userChannels, planar, func(x uint8) int64 { return int64(x) - 128 }, func(x int64) uint8 { return uint8(x + 128) })
+	}
+	return nil, nil
+}
+
+// remapFuncs returns the functions of nativeRemapFuncs for samples of type T,
+// which value and sample convert to and from signed integers.
+func remapFuncs[T any](nativeChannels, userChannels int, planar bool, value func(T) int64, sample func(int64) T) (fromNative, toNative func(dst, src unsafe.Pointer, frames int)) {
+	return func(dst, src unsafe.Pointer, frames int) {
+			remapSamples(unsafe.Slice((*T)(dst), frames*userChannels), userChannels, planar, unsafe.Slice((*T)(src), frames*nativeChannels), nativeChannels, false, frames, value, sample)
+		}, func(dst, src unsafe.Pointer, frames int) {
+			remapSamples(unsafe.Slice((*T)(dst), frames*nativeChannels), nativeChannels, false, unsafe.Slice((*T)(src), frames*userChannels), userChannels, planar, frames, value, sample)
+		}
+}
+
+// remapSamples is like remap for samples of type T in interleaved or planar buffers.
+// A mono destination receives the average of all channels, truncated toward zero.
+func remapSamples[T any](dst []T, dstChannels int, dstPlanar bool, src []T, srcChannels int, srcPlanar bool, frames int, value func(T) int64, sample func(int64) T) {
+	index := func(channels int, planar bool, i, c int) int {
+		if planar {
+			return c*frames + i
+		}
+		return i*channels + c
+	}
+	silence := sample(0)
+	for i := 0; i < frames; i++ {
+		if dstChannels == 1 && srcChannels > 1 {
+			var sum int64
+			for c := 0; c < srcChannels; c++ {
+				sum += value(src[index(srcChannels, srcPlanar, i, c)])
+			}
+			dst[index(1, dstPlanar, i, 0)] = sample(sum / int64(srcChannels))
+			continue
+		}
+		for c := 0; c < dstChannels; c++ {
+			x := silence
+			switch {
+			case srcChannels == 1:
+				x = src[index(1, srcPlanar, i, 0)]
+			case c < srcChannels:
+				x = src[index(srcChannels, srcPlanar, i, c)]
+			}
+			dst[index(dstChannels, dstPlanar, i, c)] = x
+		}
+	}
+}
@@ -0,0 +1,323 @@
+package portaudio
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// AggregateParameters includes all parameters required to open an Aggregate.
+type AggregateParameters struct {
+	// Members are the devices to combine.  The channels of the Aggregate are
+	// the channels of its members, in order.
+	Members []AggregateMember
+
+	// Master is the index in Members of the device whose clock drives the Aggregate.
+	Master int
+
+	SampleRate      float64
+	FramesPerBuffer int
+	Flags           StreamFlags
+}
+
+// An AggregateMember specifies one device of an Aggregate.  Typically Input.Device
+// and Output.Device are the same device, but either may be nil.
+type AggregateMember struct {
+	Input, Output StreamDeviceParameters
+}
+
+// An Aggregate is a virtual multi-channel device made of several devices, each of
+// which has its own stream and, in general, its own clock.
+//
+// The callback is a StreamCallback, called from the master's stream with the input channels of all members
+// and the output channels of all members, in its Buffers of any type, which are adapted as by OpenNegotiatedStream.
+// Its StreamCallbackTimeInfo is the master's, except that InputBufferAdcTime and OutputBufferDacTime
+// account for the Aggregate's latency, and its StreamCallbackFlags combine all members' flags.
+// If the Aggregate has no input or no output channels, the callback has only one Buffer.  Each member's input
+// and output pass through a RingBuffer and are adaptively resampled, using the streams'
+// timestamps, so that all members are aligned to the master and their clock drift
+// is compensated.  All channels, including the master's, are delayed alike; see Latency.
+//
+// All members' streams must report their times (see Stream.Time) on the same clock,
+// as is the case for the host APIs of a single platform.
+type Aggregate struct {
+	members                 []*aggregateMember
+	master                  *aggregateMember
+	callback                *softCallback
+	inChannels, outChannels int
+	in, out                 []float32 // combined buffers passed to callback
+	delay                   time.Duration
+	flags                   uint32 // accessed atomically; members' flags not yet passed to callback
+}
+
+type aggregateMember struct {
+	stream                  *Stream
+	inChannels, outChannels int
+	inLink, outLink         *link
+	inBuf, outBuf           []float32 // this member's channels of the combined buffers
+}
+
+// OpenAggregate opens a stream on each member of p.
+func OpenAggregate(p AggregateParameters, callback StreamCallback) (*Aggregate, error) {
+	if p.Master < 0 || p.Master >= len(p.Members) {
+		return nil, fmt.Errorf("portaudio: aggregate master %d out of range", p.Master)
+	}
+	a := &Aggregate{}
+	var maxLatency time.Duration
+	for i, mp := range p.Members {
+		m := &aggregateMember{}
+		if mp.Input.Device != nil {
+			m.inChannels = mp.Input.Channels
+		}
+		if mp.Output.Device != nil {
+			m.outChannels = mp.Output.Channels
+		}
+		if m.inChannels == 0 && m.outChannels == 0 {
+			a.Close()
+			return nil, fmt.Errorf("portaudio: aggregate member %d has no channels", i)
+		}
+		var err error
+		m.stream, err = OpenStream(StreamParameters{
+			Input:           mp.Input,
+			Output:          mp.Output,
+			SampleRate:      p.SampleRate,
+			FramesPerBuffer: p.FramesPerBuffer,
+			Flags:           p.Flags,
+		}, a.memberCallback(m))
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.members = append(a.members, m)
+		a.inChannels += m.inChannels
+		a.outChannels += m.outChannels
+
+		if info := m.stream.Info(); info != nil {
+			if info.InputLatency > maxLatency {
+				maxLatency = info.InputLatency
+			}
+			if info.OutputLatency > maxLatency {
+				maxLatency = info.OutputLatency
+			}
+		}
+	}
+	a.master = a.members[p.Master]
+	var err error
+	if a.callback, err = newSoftCallback(a.inChannels, a.outChannels, callback); err != nil {
+		a.Close()
+		return nil, err
+	}
+
+	block := FramesToDuration(int64(p.FramesPerBuffer), p.SampleRate)
+	if p.FramesPerBuffer == FramesPerBufferUnspecified {
+		block = maxLatency
+	}
+	a.delay = 2 * (maxLatency + block)
+	for _, m := range a.members {
+		if m.inChannels > 0 {
+			m.inLink = newLink(m.inChannels, p.SampleRate, a.delay)
+		}
+		if m.outChannels > 0 {
+			m.outLink = newLink(m.outChannels, p.SampleRate, a.delay)
+		}
+	}
+	a.alloc(p.FramesPerBuffer)
+	return a, nil
+}
+
+// Latency returns the latency added by the Aggregate:  the delay from the time an input
+// frame is captured to the time it is passed to the callback, and likewise from
+// the master's output time of the callback to the time an output frame is played.
+func (a *Aggregate) Latency() time.Duration {
+	return a.delay
+}
+
+// InputChannels returns the total number of input channels.
+func (a *Aggregate) InputChannels() int { return a.inChannels }
+
+// OutputChannels returns the total number of output channels.
+func (a *Aggregate) OutputChannels() int { return a.outChannels }
+
+// Stream returns the underlying stream of the i'th member.
+func (a *Aggregate) Stream(i int) *Stream { return a.members[i].stream }
+
+// Overflows returns the number of frames dropped, summed over all members,
+// because an internal buffer was full.
+func (a *Aggregate) Overflows() uint64 {
+	var n uint64
+	for _, m := range a.members {
+		if m.inLink != nil {
+			n += m.inLink.Overflows()
+		}
+		if m.outLink != nil {
+			n += m.outLink.Overflows()
+		}
+	}
+	return n
+}
+
+// Underflows returns the number of frames, summed over all members,
+// that were missing when needed and replaced by silence.
+func (a *Aggregate) Underflows() uint64 {
+	var n uint64
+	for _, m := range a.members {
+		if m.inLink != nil {
+			n += m.inLink.Underflows()
+		}
+		if m.outLink != nil {
+			n += m.outLink.Underflows()
+		}
+	}
+	return n
+}
+
+// Start starts all members' streams, the master's last.
+func (a *Aggregate) Start() error {
+	started := make([]*Stream, 0, len(a.members))
+	for _, m := range a.members {
+		if m == a.master {
+			continue
+		}
+		if err := m.stream.Start(); err != nil {
+			for _, s := range started {
+				s.Abort()
+			}
+			return err
+		}
+		started = append(started, m.stream)
+	}
+	if err := a.master.stream.Start(); err != nil {
+		for _, s := range started {
+			s.Abort()
+		}
+		return err
+	}
+	return nil
+}
+
+// Stop stops all members' streams, the master's first.
+func (a *Aggregate) Stop() error {
+	err := a.master.stream.Stop()
+	for _, m := range a.members {
+		if m == a.master {
+			continue
+		}
+		if err2 := m.stream.Stop(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Close closes all members' streams.
+func (a *Aggregate) Close() error {
+	var err error
+	for _, m := range a.members {
+		if err2 := m.stream.Close(); err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+func (a *Aggregate) memberCallback(m *aggregateMember) interface{} {
+	switch {
+	case m.inChannels > 0 && m.outChannels > 0:
+		return func(in, out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+			a.process(m, in, out, timeInfo, flags)
+		}
+	case m.inChannels > 0:
+		return func(in []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+			a.process(m, in, nil, timeInfo, flags)
+		}
+	default:
+		return func(out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+			a.process(m, nil, out, timeInfo, flags)
+		}
+	}
+}
+
+func (a *Aggregate) process(m *aggregateMember, in, out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	if m.inLink != nil {
+		m.inLink.write(in, timeInfo.InputBufferAdcTime)
+	}
+	if m == a.master {
+		a.render(in, out, timeInfo, flags)
+	} else {
+		addFlags(&a.flags, flags)
+	}
+	if m.outLink != nil {
+		m.outLink.read(out, timeInfo.OutputBufferDacTime)
+	}
+}
+
+// render calls the callback for the master's callback with the given buffers, timeInfo, and flags.
+func (a *Aggregate) render(in, out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	m := a.master
+	var frames int
+	if m.inChannels > 0 {
+		frames = len(in) / m.inChannels
+	} else {
+		frames = len(out) / m.outChannels
+	}
+	a.alloc(frames)
+
+	inTime, outTime := timeInfo.CurrentTime, timeInfo.CurrentTime
+	if m.inChannels > 0 {
+		inTime = timeInfo.InputBufferAdcTime
+	}
+	if m.outChannels > 0 {
+		outTime = timeInfo.OutputBufferDacTime
+	}
+
+	offset := 0
+	for _, m := range a.members {
+		if m.inChannels == 0 {
+			continue
+		}
+		m.inLink.read(m.inBuf, inTime)
+		copyChannels(a.in, a.inChannels, offset, m.inBuf, m.inChannels, 0, m.inChannels, frames)
+		offset += m.inChannels
+	}
+
+	timeInfo.InputBufferAdcTime = inTime - a.delay
+	timeInfo.OutputBufferDacTime = outTime + a.delay
+	if m.inChannels == 0 {
+		timeInfo.InputFrame = timeInfo.OutputFrame
+	} else if m.outChannels == 0 {
+		timeInfo.OutputFrame = timeInfo.InputFrame
+	}
+	flags |= StreamCallbackFlags(atomic.SwapUint32(&a.flags, 0))
+	a.callback.call(a.in, a.out, frames, timeInfo, flags)
+
+	offset = 0
+	for _, m := range a.members {
+		if m.outChannels == 0 {
+			continue
+		}
+		copyChannels(m.outBuf, m.outChannels, 0, a.out, a.outChannels, offset, m.outChannels, frames)
+		m.outLink.write(m.outBuf, outTime)
+		offset += m.outChannels
+	}
+}
+
+// alloc sizes the combined and per-member buffers for the given number of frames.
+// It only allocates if they have never been that large, which happens in a
+// callback only if FramesPerBuffer is unspecified.
+func (a *Aggregate) alloc(frames int) {
+	a.in = resize(a.in, frames*a.inChannels)
+	a.out = resize(a.out, frames*a.outChannels)
+	for _, m := range a.members {
+		m.inBuf = resize(m.inBuf, frames*m.inChannels)
+		m.outBuf = resize(m.outBuf, frames*m.outChannels)
+	}
+}
+
+// copyChannels copies n channels, starting at srcOffset, of the interleaved src
+// with srcChannels channels to the channels, starting at dstOffset, of the
+// interleaved dst with dstChannels channels.
+func copyChannels(dst []float32, dstChannels, dstOffset int, src []float32, srcChannels, srcOffset, n, frames int) {
+	for i := 0; i < frames; i++ {
+		copy(dst[i*dstChannels+dstOffset:i*dstChannels+dstOffset+n], src[i*srcChannels+srcOffset:])
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Package aiff reads and writes AIFF and AIFF-C audio files.
+
+It supports integer samples of 1 to 32 bits, any number of channels, any sample rate (which AIFF stores
+as an 80-bit extended float), markers, and the AIFF-C compression types that store uncompressed samples:
+"NONE" and "twos" (big-endian integers), "sowt" (little-endian integers), "in24", "in32", "23ni", "42ni",
+"raw " (8-bit offset binary), "fl32" and "FL32" (32-bit floats), and "fl64" and "FL64" (64-bit floats).
+Files are read and written as streams:  a Decoder reads samples as they are needed, and an Encoder
+writes them as they are given and fixes up the header when it is closed.
+
+Samples are decoded into and encoded from portaudio Buffers, such as those of a Stream, interleaved ([]T)
+or non-interleaved ([][]T), where T is float32, int32, portaudio.Int24, int16, int8, or uint8.
+AIFF pads samples to whole bytes, so, e.g., 12-bit samples decode exactly into []int16, whose low 4 bits
+are zero, and the Encoder clears those bits of the samples it is given.
+*/
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// Format describes the samples of an AIFF file.
+type Format struct {
+	SampleRate float64
+	Channels   int
+
+	// BitsPerSample is 1 to 32 for integer samples, which are stored in whole bytes, or 32 or 64 for float samples.
+	BitsPerSample int
+
+	// Compression is the AIFF-C compression type (see the package comment), or empty for a plain AIFF file.
+	Compression string
+}
+
+// A Marker marks a position in the samples.
+type Marker struct {
+	ID       int // positive and unique within the file
+	Position int // the number of frames preceding the marked position
+	Name     string
+}
+
+type compression struct {
+	float        bool
+	littleEndian bool
+	unsigned     bool
+	bits         int // the required BitsPerSample, or 0 for 1 to 32
+	name         string
+}
+
+var compressions = map[string]compression{
+	"NONE": {name: "not compressed"},
+	"twos": {name: "not compressed"},
+	"sowt": {littleEndian: true, name: "not compressed"},
+	"in24": {bits: 24, name: "not compressed"},
+	"in32": {bits: 32, name: "not compressed"},
+	"23ni": {littleEndian: true, bits: 24, name: "not compressed"},
+	"42ni": {littleEndian: true, bits: 32, name: "not compressed"},
+	"raw ": {unsigned: true, bits: 8, name: "not compressed"},
+	"fl32": {float: true, bits: 32, name: "32-bit floating point"},
+	"FL32": {float: true, bits: 32, name: "32-bit floating point"},
+	"fl64": {float: true, bits: 64, name: "64-bit floating point"},
+	"FL64": {float: true, bits: 64, name: "64-bit floating point"},
+}
+
+// aifcVersion is the timestamp of the AIFF-C version 1 specification, for the FVER chunk.
+const aifcVersion = 0xa2805140
+
+// unknownSize is the size of a chunk whose writer did not know its size, e.g., when streaming.
+const unknownSize = 0xffffffff
+
+// ErrFormat is returned when reading a file that is not a valid AIFF or AIFF-C file.
+var ErrFormat = errors.New("aiff: invalid format")
+
+func (f Format) validate() error {
+	c, ok := compressions[f.Compression]
+	switch {
+	case !ok && f.Compression != "":
+		return fmt.Errorf("aiff: unsupported compression type %q", f.Compression)
+	case !(f.SampleRate > 0) || math.IsInf(f.SampleRate, 0):
+		return fmt.Errorf("aiff: invalid sample rate %v", f.SampleRate)
+	case f.Channels < 1 || f.Channels > math.MaxInt16:
+		return fmt.Errorf("aiff: invalid number of channels %d", f.Channels)
+	case c.bits != 0 && f.BitsPerSample != c.bits:
+		return fmt.Errorf("aiff: compression type %q requires %d bits per sample, not %d", f.Compression, c.bits, f.BitsPerSample)
+	case !c.float && (f.BitsPerSample < 1 || f.BitsPerSample > 32):
+		return fmt.Errorf("aiff: unsupported %d-bit samples", f.BitsPerSample)
+	}
+	return nil
+}
+
+func (f Format) codec() pcm.Codec {
+	c := compressions[f.Compression]
+	return pcm.Codec{
+		Encoding: pcm.Encoding{
+			Bits:      (f.BitsPerSample + 7) / 8 * 8,
+			Float:     c.float,
+			Signed8:   !c.unsigned,
+			BigEndian: !c.littleEndian,
+			Precision: f.BitsPerSample,
+		},
+		Channels: f.Channels,
+	}
+}
+
+// extended returns the 80-bit IEEE 754 extended precision float in b.
+func extended(b []byte) float64 {
+	se := binary.BigEndian.Uint16(b)
+	m := binary.BigEndian.Uint64(b[2:])
+	x := math.Ldexp(float64(m), int(se&0x7fff)-16383-63)
+	if se&0x8000 != 0 {
+		x = -x
+	}
+	return x
+}
+
+// putExtended stores x, which must be finite, as an 80-bit IEEE 754 extended precision float in b.
+func putExtended(b []byte, x float64) {
+	var se uint16
+	if x < 0 {
+		se, x = 0x8000, -x
+	}
+	var m uint64
+	if x != 0 {
+		frac, exp := math.Frexp(x)
+		se |= uint16(exp - 1 + 16383)
+		m = uint64(math.Ldexp(frac, 64))
+	}
+	binary.BigEndian.PutUint16(b, se)
+	binary.BigEndian.PutUint64(b[2:], m)
+}
+
+// pstring appends s to b as a Pascal-style string padded to an even length.
+func pstring(b []byte, s string) []byte {
+	b = append(append(b, byte(len(s))), s...)
+	if len(s)%2 == 0 {
+		b = append(b, 0)
+	}
+	return b
+}
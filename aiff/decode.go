@@ -0,0 +1,230 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/convert"
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// A Decoder reads samples from an AIFF or AIFF-C file.
+type Decoder struct {
+	format  Format
+	markers []Marker
+	frames  int64
+	data    pcm.Reader
+}
+
+// NewDecoder reads the header of the AIFF or AIFF-C file in r, up to the start of its samples,
+// and returns a Decoder for the samples.  Chunks other than the common, marker, and sound data chunks are skipped.
+//
+// If r is an io.ReadSeeker, NewDecoder reads the chunks that follow the sound data, too,
+// and then seeks back to the samples.  Otherwise, markers that follow the sound data are not read.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var form [12]byte
+	if _, err := io.ReadFull(r, form[:]); err != nil {
+		return nil, eofToFormatError(err)
+	}
+	if string(form[:4]) != "FORM" || string(form[8:]) != "AIFF" && string(form[8:]) != "AIFC" {
+		return nil, ErrFormat
+	}
+	aifc := string(form[8:]) == "AIFC"
+	formEnd := int64(-1)
+	if size := binary.BigEndian.Uint32(form[4:]); size != unknownSize {
+		formEnd = 8 + int64(size)
+	}
+	d := &Decoder{data: pcm.Reader{R: r}}
+	rs, seekable := r.(io.ReadSeeker)
+	var (
+		haveComm   bool
+		numFrames  uint32
+		dataOffset int64 // the offset of the samples, if seekable and found
+		dataSize   int64 = -1
+	)
+	for {
+		// Having found the samples, stop at the end of the FORM chunk or the file and return to them.
+		end := false
+		if dataOffset > 0 && formEnd >= 0 {
+			pos, err := rs.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			end = pos >= formEnd
+		}
+		var hdr [8]byte
+		if !end {
+			if _, err := io.ReadFull(r, hdr[:]); err == io.EOF && dataOffset > 0 {
+				end = true
+			} else if err != nil {
+				return nil, eofToFormatError(err)
+			}
+		}
+		if end {
+			if _, err := rs.Seek(dataOffset, io.SeekStart); err != nil {
+				return nil, err
+			}
+			break
+		}
+		id, size := string(hdr[:4]), binary.BigEndian.Uint32(hdr[4:])
+		if id == "SSND" {
+			if !haveComm {
+				return nil, fmt.Errorf("aiff: sound data chunk precedes common chunk")
+			}
+			var ssnd [8]byte
+			if _, err := io.ReadFull(r, ssnd[:]); err != nil {
+				return nil, eofToFormatError(err)
+			}
+			offset := binary.BigEndian.Uint32(ssnd[:])
+			if _, err := io.CopyN(io.Discard, r, int64(offset)); err != nil {
+				return nil, eofToFormatError(err)
+			}
+			if size != unknownSize {
+				if size < 8+offset {
+					return nil, ErrFormat
+				}
+				dataSize = int64(size - 8 - offset)
+			}
+			if !seekable || size == unknownSize {
+				break
+			}
+			pos, err := rs.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			dataOffset = pos
+			if _, err := rs.Seek(dataSize+int64(size%2), io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if size > 1<<24 {
+			if id == "COMM" || id == "MARK" {
+				return nil, ErrFormat
+			}
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, eofToFormatError(err)
+			}
+			continue
+		}
+		b := make([]byte, size+size%2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, eofToFormatError(err)
+		}
+		b = b[:size]
+		switch id {
+		case "COMM":
+			n, err := d.format.parse(b, aifc)
+			if err != nil {
+				return nil, err
+			}
+			numFrames, haveComm = n, true
+		case "MARK":
+			m, err := parseMarkers(b)
+			if err != nil {
+				return nil, err
+			}
+			d.markers = m
+		}
+	}
+	d.data.Codec = d.format.codec()
+	d.frames, d.data.Remaining = -1, -1
+	if dataSize >= 0 {
+		d.frames = dataSize / int64(d.data.Codec.FrameSize())
+		if int64(numFrames) < d.frames {
+			d.frames = int64(numFrames)
+		}
+		d.data.Remaining = d.frames * int64(d.data.Codec.FrameSize())
+	}
+	return d, nil
+}
+
+func eofToFormatError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFormat
+	}
+	return err
+}
+
+// parse parses the body of a common chunk and returns the number of frames.
+func (f *Format) parse(b []byte, aifc bool) (uint32, error) {
+	if len(b) < 18 {
+		return 0, ErrFormat
+	}
+	be := binary.BigEndian
+	f.Channels = int(int16(be.Uint16(b)))
+	frames := be.Uint32(b[2:])
+	f.BitsPerSample = int(int16(be.Uint16(b[6:])))
+	f.SampleRate = extended(b[8:])
+	if aifc {
+		if len(b) < 22 {
+			return 0, ErrFormat
+		}
+		f.Compression = string(b[18:22])
+		if c, ok := compressions[f.Compression]; ok && c.float {
+			// Some writers leave the sample size zero.
+			f.BitsPerSample = c.bits
+		}
+	}
+	return frames, f.validate()
+}
+
+func parseMarkers(b []byte) ([]Marker, error) {
+	if len(b) < 2 {
+		return nil, ErrFormat
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	markers := make([]Marker, 0, n)
+	for i := 0; i < n; i++ {
+		if len(b) < 7 || len(b) < 7+int(b[6]) {
+			return nil, ErrFormat
+		}
+		name := string(b[7 : 7+b[6]])
+		markers = append(markers, Marker{
+			ID:       int(int16(binary.BigEndian.Uint16(b))),
+			Position: int(binary.BigEndian.Uint32(b[2:])),
+			Name:     name,
+		})
+		b = b[7+len(name):]
+		if len(name)%2 == 0 && len(b) > 0 {
+			b = b[1:]
+		}
+	}
+	return markers, nil
+}
+
+// Format returns the format of the file.
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Markers returns the markers of the file, in the order in which they are stored.
+func (d *Decoder) Markers() []Marker {
+	return d.markers
+}
+
+// Frames returns the number of frames in the file, or -1 if the header does not say,
+// as in a file that was streamed.
+func (d *Decoder) Frames() int64 {
+	return d.frames
+}
+
+// SetConvertOptions sets the options for quantizing samples decoded into a narrower sample type.
+// By default they are truncated.
+func (d *Decoder) SetConvertOptions(opts convert.Options) {
+	d.data.Codec.Converter = convert.NewConverter(d.format.Channels, opts)
+}
+
+// Read decodes as many frames as fit into buf (see the package comment) and returns the number read.
+// At the end of the samples, it returns 0 and io.EOF.  If the file ends before the end
+// given by its header, Read returns io.ErrUnexpectedEOF.
+func (d *Decoder) Read(buf portaudio.Buffer) (int, error) {
+	n, err := d.data.Codec.BufferFrames(buf)
+	if err != nil {
+		return 0, fmt.Errorf("aiff: %v", err)
+	}
+	return d.data.Read(buf, n)
+}
@@ -0,0 +1,202 @@
+package aiff
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/convert"
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// ErrTooLarge is returned by Encoder.Write when the sample data would exceed the 4 GiB limit of an AIFF file.
+var ErrTooLarge = errors.New("aiff: file too large")
+
+// An Encoder writes samples to an AIFF file, or an AIFF-C file if the format has a compression type.
+//
+// The header's sizes and number of frames are written as unknown, as for a stream, until Close writes them
+// if the underlying writer is an io.WriteSeeker.
+type Encoder struct {
+	w       io.Writer
+	format  Format
+	markers []Marker
+	codec   pcm.Codec
+
+	// framesOffset is the offset of the common chunk's number of frames.
+	framesOffset int64
+	headerSize   int64
+	dataSize     int64
+	buf          []byte
+	err          error
+}
+
+// NewEncoder writes the header of an AIFF or AIFF-C file with format f to w and returns an Encoder for its samples.
+func NewEncoder(w io.Writer, f Format) (*Encoder, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	e := &Encoder{w: w, format: f, codec: f.codec()}
+	be := binary.BigEndian
+	comm := make([]byte, 18, 64)
+	be.PutUint16(comm, uint16(f.Channels))
+	be.PutUint16(comm[6:], uint16(f.BitsPerSample))
+	putExtended(comm[8:], f.SampleRate)
+	if f.Compression != "" {
+		comm = append(comm, f.Compression...)
+		comm = pstring(comm, compressions[f.Compression].name)
+	}
+
+	var hdr []byte
+	chunk := func(id string, body []byte) {
+		var h [8]byte
+		copy(h[:], id)
+		be.PutUint32(h[4:], uint32(len(body)))
+		hdr = append(append(hdr, h[:]...), body...)
+	}
+	chunk("FORM", nil)
+	if f.Compression != "" {
+		hdr = append(hdr, "AIFC"...)
+		var version [4]byte
+		be.PutUint32(version[:], aifcVersion)
+		chunk("FVER", version[:])
+	} else {
+		hdr = append(hdr, "AIFF"...)
+	}
+	e.framesOffset = int64(len(hdr)) + 8 + 2
+	chunk("COMM", comm)
+	chunk("SSND", make([]byte, 8)) // with zero offset and block size
+	be.PutUint32(hdr[4:], unknownSize)
+	be.PutUint32(hdr[len(hdr)-12:], unknownSize)
+	e.headerSize = int64(len(hdr))
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Format returns the format of the file.
+func (e *Encoder) Format() Format {
+	return e.format
+}
+
+// Frames returns the number of frames written.
+func (e *Encoder) Frames() int64 {
+	return e.dataSize / int64(e.codec.FrameSize())
+}
+
+// SetConvertOptions sets the options for quantizing samples encoded into a narrower sample type.
+// By default they are truncated.
+func (e *Encoder) SetConvertOptions(opts convert.Options) {
+	e.codec.Converter = convert.NewConverter(e.format.Channels, opts)
+}
+
+// AddMarker adds a marker, which Close writes after the samples.  Because a reader of a stream
+// would mistake the markers for samples, the underlying writer must be an io.WriteSeeker.
+func (e *Encoder) AddMarker(m Marker) error {
+	if _, ok := e.w.(io.WriteSeeker); !ok {
+		return fmt.Errorf("aiff: markers require an io.WriteSeeker")
+	}
+	switch {
+	case m.ID < 1 || m.ID > math.MaxInt16:
+		return fmt.Errorf("aiff: invalid marker ID %d", m.ID)
+	case m.Position < 0 || int64(m.Position) > math.MaxUint32:
+		return fmt.Errorf("aiff: invalid marker position %d", m.Position)
+	case len(m.Name) > 255:
+		return fmt.Errorf("aiff: marker name longer than 255 bytes")
+	case len(e.markers) == math.MaxUint16:
+		return fmt.Errorf("aiff: too many markers")
+	}
+	for _, m2 := range e.markers {
+		if m2.ID == m.ID {
+			return fmt.Errorf("aiff: duplicate marker ID %d", m.ID)
+		}
+	}
+	e.markers = append(e.markers, m)
+	return nil
+}
+
+// Write encodes the frames of buf (see the package comment) and returns the number written.
+func (e *Encoder) Write(buf portaudio.Buffer) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.codec.BufferFrames(buf)
+	if err != nil {
+		return 0, fmt.Errorf("aiff: %v", err)
+	}
+	size := int64(n * e.codec.FrameSize())
+	if e.headerSize-8+e.dataSize+size+1 >= unknownSize {
+		return 0, ErrTooLarge
+	}
+	e.buf = e.codec.Encode(e.buf[:0], buf, n)
+	m, err := e.w.Write(e.buf)
+	e.dataSize += int64(m)
+	if err != nil {
+		e.err = err
+		return m / e.codec.FrameSize(), err
+	}
+	return n, nil
+}
+
+// Close finishes the file:  it pads the samples to an even length, as IFF requires,
+// and, if the underlying writer is an io.WriteSeeker, writes the markers and the sizes in the header,
+// leaving the writer positioned at the end of the file.  It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("aiff: encoder closed")
+	be := binary.BigEndian
+	var tail []byte
+	if e.dataSize%2 == 1 {
+		tail = append(tail, 0)
+	}
+	if len(e.markers) > 0 {
+		mark := make([]byte, 2)
+		be.PutUint16(mark, uint16(len(e.markers)))
+		for _, m := range e.markers {
+			var b [6]byte
+			be.PutUint16(b[:], uint16(m.ID))
+			be.PutUint32(b[2:], uint32(m.Position))
+			mark = pstring(append(mark, b[:]...), m.Name)
+		}
+		var h [8]byte
+		copy(h[:], "MARK")
+		be.PutUint32(h[4:], uint32(len(mark)))
+		tail = append(append(tail, h[:]...), mark...)
+	}
+	end := e.headerSize + e.dataSize + int64(len(tail))
+	if end-8 >= unknownSize {
+		return ErrTooLarge
+	}
+	if _, err := e.w.Write(tail); err != nil {
+		return err
+	}
+	ws, ok := e.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	put := func(offset int64, x uint32) error {
+		if _, err := ws.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		var b [4]byte
+		be.PutUint32(b[:], x)
+		_, err := ws.Write(b[:])
+		return err
+	}
+	if err := put(4, uint32(end-8)); err != nil {
+		return err
+	}
+	if err := put(e.framesOffset, uint32(e.Frames())); err != nil {
+		return err
+	}
+	if err := put(e.headerSize-12, uint32(8+e.dataSize)); err != nil {
+		return err
+	}
+	_, err := ws.Seek(end, io.SeekStart)
+	return err
+}
@@ -0,0 +1,191 @@
+/*
+Package audiofile plays and records WAV and AIFF files with a single call.
+
+PlayFile and RecordFile open a portaudio stream (with OpenNegotiatedStream, so the device need not
+support the file's sample rate or number of channels), pass samples between it and the file through a
+RingBuffer, and block until the file is done or their context is cancelled.  PortAudio must be initialized.
+*/
+package audiofile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/aiff"
+	"github.com/gordonklaus/portaudio/resample"
+	"github.com/gordonklaus/portaudio/wav"
+)
+
+// Options configure PlayFile and RecordFile.  Zero fields take their defaults.
+type Options struct {
+	// Device is the device to play or record with.  The default is the default output or input device.
+	Device *portaudio.DeviceInfo
+
+	// Latency is the suggested latency of the stream.  The default is the device's default high latency.
+	Latency time.Duration
+
+	// FramesPerBuffer is the number of frames per buffer of the stream.  The default is FramesPerBufferUnspecified.
+	FramesPerBuffer int
+
+	// Resample is the quality of resampling, if the device does not support the file's sample rate.
+	Resample resample.Quality
+
+	// Buffer is the duration of the samples buffered between the file and the stream.  The default is half a second.
+	Buffer time.Duration
+
+	// The remaining options apply only to RecordFile, which writes a WAV file if the path ends in
+	// ".wav" or ".wave" and an AIFF file if it ends in ".aif", ".aiff", or ".aifc".
+
+	// SampleRate is the sample rate of the file.  The default is the device's default sample rate.
+	SampleRate float64
+
+	// Channels is the number of channels of the file.  The default is 2, or 1 if the device is mono.
+	Channels int
+
+	// BitsPerSample and Float describe the samples of the file.  The default is 16-bit integers,
+	// or 32-bit floats if Float is set.
+	BitsPerSample int
+	Float         bool
+
+	// Duration, if nonzero, is how long to record.  Otherwise, RecordFile records until its context is done.
+	Duration time.Duration
+}
+
+// Stats describe a completed PlayFile or RecordFile.
+type Stats struct {
+	// Frames is the number of frames played from or recorded to the file, at its sample rate.
+	Frames   int64
+	Duration time.Duration
+
+	// Underflows and Overflows are the numbers of callbacks that PortAudio flagged with
+	// InputUnderflow or OutputUnderflow and with InputOverflow or OutputOverflow.
+	Underflows, Overflows int
+
+	// Starved is the number of frames of silence played because the file was not read in time.
+	// Dropped is the number of frames of input discarded because the file was not written in time.
+	Starved, Dropped int64
+
+	// Negotiation reports the parameters of the stream.
+	Negotiation *portaudio.Negotiation
+}
+
+// counters are updated by a StreamCallback and read atomically.
+type counters struct {
+	frames, starved, dropped, underflows, overflows int64
+}
+
+func (c *counters) flag(flags portaudio.StreamCallbackFlags) {
+	if flags&(portaudio.InputUnderflow|portaudio.OutputUnderflow) != 0 {
+		atomic.AddInt64(&c.underflows, 1)
+	}
+	if flags&(portaudio.InputOverflow|portaudio.OutputOverflow) != 0 {
+		atomic.AddInt64(&c.overflows, 1)
+	}
+}
+
+func (c *counters) stats(sampleRate float64, n *portaudio.Negotiation) *Stats {
+	frames := atomic.LoadInt64(&c.frames)
+	return &Stats{
+		Frames:      frames,
+		Duration:    portaudio.FramesToDuration(frames, sampleRate),
+		Underflows:  int(atomic.LoadInt64(&c.underflows)),
+		Overflows:   int(atomic.LoadInt64(&c.overflows)),
+		Starved:     atomic.LoadInt64(&c.starved),
+		Dropped:     atomic.LoadInt64(&c.dropped),
+		Negotiation: n,
+	}
+}
+
+func (o Options) buffer() time.Duration {
+	if o.Buffer > 0 {
+		return o.Buffer
+	}
+	return time.Second / 2
+}
+
+// newRingBuffer returns a RingBuffer holding opts.Buffer of samples.
+func newRingBuffer(opts Options, sampleRate float64, channels int) *portaudio.RingBuffer[float32] {
+	frames := portaudio.DurationToFrames(opts.buffer(), sampleRate)
+	if frames < 1024 {
+		frames = 1024
+	}
+	return portaudio.NewRingBuffer[float32](int(frames) * channels)
+}
+
+// pollInterval returns how often the file is serviced.
+func pollInterval(opts Options) time.Duration {
+	d := opts.buffer() / 4
+	if d > 100*time.Millisecond {
+		d = 100 * time.Millisecond
+	}
+	return d
+}
+
+type decoder interface {
+	Read(buf portaudio.Buffer) (int, error)
+}
+
+// openDecoder detects the type of the file f and returns a decoder for it.
+func openDecoder(f *os.File) (d decoder, sampleRate float64, channels int, err error) {
+	var hdr [12]byte
+	if _, err := io.ReadFull(f, hdr[:]); err != nil {
+		return nil, 0, 0, fmt.Errorf("audiofile: unrecognized file format")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, 0, 0, err
+	}
+	switch {
+	case string(hdr[:4]) == "RIFF" && string(hdr[8:]) == "WAVE":
+		d, err := wav.NewDecoder(f)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return d, float64(d.Format().SampleRate), d.Format().Channels, nil
+	case string(hdr[:4]) == "FORM" && (string(hdr[8:]) == "AIFF" || string(hdr[8:]) == "AIFC"):
+		d, err := aiff.NewDecoder(f)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		return d, d.Format().SampleRate, d.Format().Channels, nil
+	}
+	return nil, 0, 0, fmt.Errorf("audiofile: unrecognized file format")
+}
+
+type encoder interface {
+	Write(buf portaudio.Buffer) (int, error)
+	Close() error
+}
+
+// newEncoder returns an encoder for a file of the type given by the extension of path.
+func newEncoder(w io.Writer, path string, sampleRate float64, channels int, opts Options) (encoder, error) {
+	bits := opts.BitsPerSample
+	if bits == 0 {
+		bits = 16
+		if opts.Float {
+			bits = 32
+		}
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".wav", ".wave":
+		return wav.NewEncoder(w, wav.Format{
+			SampleRate:    int(sampleRate + .5),
+			Channels:      channels,
+			BitsPerSample: bits,
+			Float:         opts.Float,
+		})
+	case ".aif", ".aiff", ".aifc":
+		f := aiff.Format{SampleRate: sampleRate, Channels: channels, BitsPerSample: bits}
+		if opts.Float {
+			f.Compression = fmt.Sprintf("fl%d", bits)
+		}
+		return aiff.NewEncoder(w, f)
+	default:
+		return nil, fmt.Errorf("audiofile: unknown file type %q", ext)
+	}
+}
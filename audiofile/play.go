@@ -0,0 +1,105 @@
+package audiofile
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// PlayFile plays the WAV or AIFF file at path and returns when it has been played,
+// or when ctx is done, in which case it stops playback immediately and returns ctx.Err().
+// The device plays the file's channels and sample rate if it supports them;
+// otherwise channels are remapped and samples resampled (see OpenNegotiatedStream).
+func PlayFile(ctx context.Context, path string, opts Options) (*Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, sampleRate, channels, err := openDecoder(f)
+	if err != nil {
+		return nil, err
+	}
+
+	dev := opts.Device
+	if dev == nil {
+		if dev, err = portaudio.DefaultOutputDevice(); err != nil {
+			return nil, err
+		}
+	}
+	latency := opts.Latency
+	if latency == 0 {
+		latency = dev.DefaultHighOutputLatency
+	}
+
+	ring := newRingBuffer(opts, sampleRate, channels)
+	buf := make([]float32, ring.Cap())
+	var eof int32 // set when the whole file is in ring
+	fill := func() error {
+		n := ring.AvailableToWrite()
+		n -= n % channels
+		if n == 0 || atomic.LoadInt32(&eof) != 0 {
+			return nil
+		}
+		frames, err := d.Read(buf[:n])
+		ring.Write(buf[:frames*channels])
+		if err == io.EOF {
+			atomic.StoreInt32(&eof, 1)
+			return nil
+		}
+		return err
+	}
+	if err := fill(); err != nil {
+		return nil, err
+	}
+
+	var c counters
+	p := portaudio.StreamParameters{
+		Output:          portaudio.StreamDeviceParameters{Device: dev, Channels: channels, Latency: latency},
+		SampleRate:      sampleRate,
+		FramesPerBuffer: opts.FramesPerBuffer,
+		Resample:        opts.Resample,
+	}
+	s, n, err := portaudio.OpenNegotiatedStream(p, func(out []float32, _ portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
+		read := ring.Read(out)
+		for i := range out[read:] {
+			out[read+i] = 0
+		}
+		atomic.AddInt64(&c.frames, int64(read/channels))
+		if read < len(out) && atomic.LoadInt32(&eof) == 0 {
+			atomic.AddInt64(&c.starved, int64((len(out)-read)/channels))
+		}
+		c.flag(flags)
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return nil, err
+	}
+
+	t := time.NewTicker(pollInterval(opts))
+	defer t.Stop()
+	for atomic.LoadInt32(&eof) == 0 || ring.AvailableToRead() > 0 {
+		select {
+		case <-ctx.Done():
+			s.Abort()
+			return c.stats(sampleRate, n), ctx.Err()
+		case <-t.C:
+		}
+		if err := fill(); err != nil {
+			s.Abort()
+			return c.stats(sampleRate, n), err
+		}
+	}
+	// Stop returns after the buffered output has been played.
+	if err := s.Stop(); err != nil {
+		return c.stats(sampleRate, n), err
+	}
+	return c.stats(sampleRate, n), nil
+}
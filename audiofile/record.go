@@ -0,0 +1,127 @@
+package audiofile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// RecordFile records to a new WAV or AIFF file at path (see Options) until opts.Duration has been
+// recorded or ctx is done, in which case it finishes the file normally and returns a nil error.
+// The file has the sample rate and number of channels of opts even if the device does not support them;
+// channels are then remapped and samples resampled (see OpenNegotiatedStream).
+func RecordFile(ctx context.Context, path string, opts Options) (_ *Stats, err error) {
+	dev := opts.Device
+	if dev == nil {
+		if dev, err = portaudio.DefaultInputDevice(); err != nil {
+			return nil, err
+		}
+	}
+	channels := opts.Channels
+	if channels == 0 {
+		channels = 2
+		if dev.MaxInputChannels < 2 {
+			channels = 1
+		}
+	}
+	if channels < 1 {
+		return nil, fmt.Errorf("audiofile: invalid number of channels %d", channels)
+	}
+	sampleRate := opts.SampleRate
+	if sampleRate == 0 {
+		sampleRate = dev.DefaultSampleRate
+	}
+	latency := opts.Latency
+	if latency == 0 {
+		latency = dev.DefaultHighInputLatency
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	e, err := newEncoder(f, path, sampleRate, channels, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ring := newRingBuffer(opts, sampleRate, channels)
+	var c counters
+	p := portaudio.StreamParameters{
+		Input:           portaudio.StreamDeviceParameters{Device: dev, Channels: channels, Latency: latency},
+		SampleRate:      sampleRate,
+		FramesPerBuffer: opts.FramesPerBuffer,
+		Resample:        opts.Resample,
+	}
+	s, n, err := portaudio.OpenNegotiatedStream(p, func(in []float32, _ portaudio.StreamCallbackTimeInfo, flags portaudio.StreamCallbackFlags) {
+		write := ring.AvailableToWrite()
+		write -= write % channels
+		if write > len(in) {
+			write = len(in)
+		}
+		ring.Write(in[:write])
+		atomic.AddInt64(&c.dropped, int64((len(in)-write)/channels))
+		c.flag(flags)
+	})
+	if err != nil {
+		e.Close()
+		return nil, err
+	}
+	defer s.Close()
+
+	limit := int64(-1)
+	if opts.Duration > 0 {
+		limit = portaudio.DurationToFrames(opts.Duration, sampleRate)
+	}
+	buf := make([]float32, ring.Cap())
+	// drain writes the recorded frames to the file and reports whether the limit has been reached.
+	drain := func() (bool, error) {
+		frames := int64(ring.Read(buf) / channels)
+		recorded := atomic.LoadInt64(&c.frames)
+		if limit >= 0 && recorded+frames > limit {
+			frames = limit - recorded
+		}
+		if _, err := e.Write(buf[:frames*int64(channels)]); err != nil {
+			return false, err
+		}
+		atomic.AddInt64(&c.frames, frames)
+		return limit >= 0 && recorded+frames >= limit, nil
+	}
+
+	if err := s.Start(); err != nil {
+		e.Close()
+		return nil, err
+	}
+	t := time.NewTicker(pollInterval(opts))
+	defer t.Stop()
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-t.C:
+			if done, err = drain(); err != nil {
+				s.Abort()
+				e.Close()
+				return c.stats(sampleRate, n), err
+			}
+		}
+	}
+	if err := s.Stop(); err != nil {
+		e.Close()
+		return c.stats(sampleRate, n), err
+	}
+	if _, err := drain(); err != nil {
+		e.Close()
+		return c.stats(sampleRate, n), err
+	}
+	return c.stats(sampleRate, n), e.Close()
+}
@@ -0,0 +1,130 @@
+package portaudio
+
+import (
+	"fmt"
+	"time"
+)
+
+// A BlockAdapter adapts a stream whose callback is called with varying numbers of frames,
+// such as one opened with FramesPerBufferUnspecified, to a callback that always
+// receives exactly the same number of frames.  It buffers input and output internally,
+// which adds up to one block (less one frame) of latency; see Latency.
+//
+// Buffers are interleaved, with the numbers of channels given to NewBlockAdapter.
+// Pass the Callback method to OpenStream as the StreamCallback.
+//
+// The StreamCallbackTimeInfo passed to the adapted callback describes each block:
+// InputBufferAdcTime and InputFrame describe its first input frame and
+// OutputBufferDacTime and OutputFrame describe its first output frame.
+// The StreamCallbackFlags passed with a block accumulate the flags of all
+// stream callbacks since the previous block.
+type BlockAdapter[I, O any] struct {
+	callback                func(in []I, out []O, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags)
+	frames                  int
+	inChannels, outChannels int
+	sampleRate              float64
+	in                      []I // input frames not yet passed to callback
+	out                     []O // output frames not yet passed to the stream
+	outBlock                []O
+	flags                   StreamCallbackFlags
+}
+
+// NewBlockAdapter returns a BlockAdapter that calls callback with blocks of the given number of frames.
+// One of inChannels or outChannels may be zero, for an input- or output-only stream.
+//
+// The adapter's buffers are sized for stream buffers of up to frames+1 frames.  If the stream
+// passes more frames than that, Callback grows them, allocating once for each new maximum size.
+func NewBlockAdapter[I, O any](sampleRate float64, frames, inChannels, outChannels int, callback func(in []I, out []O, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags)) (*BlockAdapter[I, O], error) {
+	switch {
+	case frames < 1:
+		return nil, fmt.Errorf("portaudio: invalid number of frames per block %d", frames)
+	case !(sampleRate > 0):
+		return nil, fmt.Errorf("portaudio: invalid sample rate %v", sampleRate)
+	case inChannels < 0 || outChannels < 0 || inChannels == 0 && outChannels == 0:
+		return nil, fmt.Errorf("portaudio: invalid numbers of input and output channels %d and %d", inChannels, outChannels)
+	}
+	a := &BlockAdapter[I, O]{
+		callback:    callback,
+		frames:      frames,
+		inChannels:  inChannels,
+		outChannels: outChannels,
+		sampleRate:  sampleRate,
+		in:          make([]I, 0, 2*frames*inChannels),
+		out:         make([]O, 0, 2*frames*outChannels),
+		outBlock:    make([]O, frames*outChannels),
+	}
+	if inChannels > 0 && outChannels > 0 {
+		// Prime the output so that it never runs dry while input accumulates.
+		a.out = a.out[:(frames-1)*outChannels]
+	}
+	return a, nil
+}
+
+// Frames returns the number of frames in each block.
+func (a *BlockAdapter[I, O]) Frames() int {
+	return a.frames
+}
+
+// Latency returns the maximum latency added by the adapter,
+// which is the duration of one block less one frame.
+func (a *BlockAdapter[I, O]) Latency() time.Duration {
+	return a.frameDuration(a.frames - 1)
+}
+
+func (a *BlockAdapter[I, O]) frameDuration(frames int) time.Duration {
+	return time.Duration(float64(frames) / a.sampleRate * float64(time.Second))
+}
+
+// Callback is the StreamCallback to be passed to OpenStream.
+func (a *BlockAdapter[I, O]) Callback(in []I, out []O, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	a.flags |= flags
+	var inQueued, outQueued, frames int
+	if a.inChannels > 0 {
+		inQueued = len(a.in) / a.inChannels
+		a.in = append(a.in, in...)
+	}
+	if a.outChannels > 0 {
+		outQueued = len(a.out) / a.outChannels
+		frames = len(out) / a.outChannels
+	}
+	for {
+		if a.inChannels > 0 {
+			if len(a.in) < a.frames*a.inChannels {
+				break
+			}
+		} else if outQueued >= frames {
+			break
+		}
+
+		ti := StreamCallbackTimeInfo{CurrentTime: timeInfo.CurrentTime}
+		var blockIn []I
+		if a.inChannels > 0 {
+			ti.InputBufferAdcTime = timeInfo.InputBufferAdcTime - a.frameDuration(inQueued)
+			ti.InputFrame = timeInfo.InputFrame - int64(inQueued)
+			blockIn = a.in[:a.frames*a.inChannels]
+		}
+		if a.outChannels > 0 {
+			ti.OutputBufferDacTime = timeInfo.OutputBufferDacTime + a.frameDuration(outQueued)
+			ti.OutputFrame = timeInfo.OutputFrame + int64(outQueued)
+		}
+		a.callback(blockIn, a.outBlock, ti, a.flags)
+		a.flags = 0
+
+		if a.inChannels > 0 {
+			a.in = a.in[:copy(a.in, a.in[len(blockIn):])]
+			inQueued -= a.frames
+		}
+		if a.outChannels > 0 {
+			a.out = append(a.out, a.outBlock...)
+			outQueued += a.frames
+		}
+	}
+	if a.outChannels > 0 {
+		n := copy(out, a.out)
+		a.out = a.out[:copy(a.out, a.out[n:])]
+		var zero O
+		for i := range out[n:] {
+			out[n+i] = zero
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package portaudio
+
+import (
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// A Bridge is a software full-duplex stream whose input and output are on
+// different devices, possibly of different host APIs, for which
+// a native full-duplex stream would fail with BadIODeviceCombination.
+//
+// It opens an input stream and an output stream and passes input to the
+// output callback through a RingBuffer.  Because the two devices' clocks drift,
+// the input is adaptively resampled, using the streams' timestamps, so that
+// each input frame reaches the output a constant delay after it was captured.
+// The part of that delay added by the Bridge is reported by Latency.
+//
+// Both devices' streams must report their times (see Stream.Time) on the same clock,
+// as is the case for the host APIs of a single platform.
+//
+// The callback is a StreamCallback with input and output Buffers, as for a native
+// full-duplex stream, and is called from the output stream.  Its StreamCallbackTimeInfo is
+// the output stream's, except that InputBufferAdcTime is the time at which its input
+// was captured, and its StreamCallbackFlags combine both streams' flags.
+type Bridge struct {
+	in, out  *Stream
+	callback *softCallback
+	link     *link
+	block    []float32 // resampled input passed to callback
+	added    time.Duration
+	inFlags  uint32 // accessed atomically; input flags not yet passed to callback
+}
+
+// OpenBridge opens a Bridge on p.Input.Device and p.Output.Device, both of which must be non-nil.
+// Both streams are opened at p.SampleRate with p.FramesPerBuffer and p.Flags.
+// The callback's Buffers may be of any type, as they are adapted to the streams' as by OpenNegotiatedStream.
+func OpenBridge(p StreamParameters, callback StreamCallback) (*Bridge, error) {
+	if p.Input.Device == nil || p.Output.Device == nil {
+		return nil, fmt.Errorf("portaudio: OpenBridge requires an input and an output device")
+	}
+	c, err := newSoftCallback(p.Input.Channels, p.Output.Channels, callback)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bridge{callback: c}
+	pin, pout := p, p
+	pin.Output = StreamDeviceParameters{}
+	pout.Input = StreamDeviceParameters{}
+	b.in, err = OpenStream(pin, b.inputCallback)
+	if err != nil {
+		return nil, err
+	}
+	b.out, err = OpenStream(pout, b.outputCallback)
+	if err != nil {
+		b.in.Close()
+		return nil, err
+	}
+
+	inLatency, outLatency := b.in.Info().InputLatency, b.out.Info().OutputLatency
+	b.added = FramesToDuration(2*int64(p.FramesPerBuffer), p.SampleRate)
+	if p.FramesPerBuffer == FramesPerBufferUnspecified {
+		b.added = inLatency
+		if outLatency > b.added {
+			b.added = outLatency
+		}
+	}
+	b.link = newLink(p.Input.Channels, p.SampleRate, inLatency+b.added+outLatency)
+	return b, nil
+}
+
+// Latency returns the latency added by the Bridge, in addition to the
+// input latency of the input stream and the output latency of the output stream.
+func (b *Bridge) Latency() time.Duration {
+	return b.added
+}
+
+// InputStream returns the underlying input stream.
+func (b *Bridge) InputStream() *Stream { return b.in }
+
+// OutputStream returns the underlying output stream.
+func (b *Bridge) OutputStream() *Stream { return b.out }
+
+// Overflows returns the number of input frames dropped because the Bridge's buffer was full.
+func (b *Bridge) Overflows() uint64 {
+	return b.link.Overflows()
+}
+
+// Underflows returns the number of input frames that were missing when needed,
+// and replaced by silence.
+func (b *Bridge) Underflows() uint64 {
+	return b.link.Underflows()
+}
+
+// Start starts both streams.
+func (b *Bridge) Start() error {
+	if err := b.in.Start(); err != nil {
+		return err
+	}
+	if err := b.out.Start(); err != nil {
+		b.in.Abort()
+		return err
+	}
+	return nil
+}
+
+// Stop stops both streams.
+func (b *Bridge) Stop() error {
+	err := b.out.Stop()
+	if err2 := b.in.Stop(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Close closes both streams.
+func (b *Bridge) Close() error {
+	err := b.out.Close()
+	if err2 := b.in.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+func (b *Bridge) inputCallback(in []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	b.link.write(in, timeInfo.InputBufferAdcTime)
+	addFlags(&b.inFlags, flags)
+}
+
+// addFlags atomically adds flags to *p.
+func addFlags(p *uint32, flags StreamCallbackFlags) {
+	for {
+		old := atomic.LoadUint32(p)
+		if atomic.CompareAndSwapUint32(p, old, old|uint32(flags)) {
+			return
+		}
+	}
+}
+
+func (b *Bridge) outputCallback(out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	frames := len(out) / b.out.outChannels()
+	n := frames * b.link.channels
+	if cap(b.block) < n {
+		b.block = make([]float32, n)
+	}
+	b.block = b.block[:n]
+	b.link.read(b.block, timeInfo.OutputBufferDacTime)
+	timeInfo.InputBufferAdcTime = timeInfo.OutputBufferDacTime - b.link.delay
+	timeInfo.InputFrame = timeInfo.OutputFrame
+	flags |= StreamCallbackFlags(atomic.SwapUint32(&b.inFlags, 0))
+	b.callback.call(b.block, out, frames, timeInfo, flags)
+}
+
+// A softCallback calls a StreamCallback for a stream made of other streams, such as a Bridge,
+// adapting its Buffers from and to interleaved float32 as OpenNegotiatedStream does.
+type softCallback struct {
+	s       *Stream // never opened; holds the callback, its arguments, and its adapter
+	adapter *streamAdapter
+}
+
+// newSoftCallback returns a softCallback for a stream with the given numbers of channels,
+// one of which may be zero for an input- or output-only stream.
+func newSoftCallback(inChannels, outChannels int, callback StreamCallback) (*softCallback, error) {
+	fun := reflect.ValueOf(callback)
+	if fun.Kind() != reflect.Func {
+		return nil, fmt.Errorf("portaudio: expected a StreamCallback, got %T", callback)
+	}
+	// The device is only a placeholder; the parameters just tell initCallback which Buffers to expect.
+	var p StreamParameters
+	if inChannels > 0 {
+		p.Input = StreamDeviceParameters{Device: &DeviceInfo{}, Channels: inChannels}
+	}
+	if outChannels > 0 {
+		p.Output = StreamDeviceParameters{Device: &DeviceInfo{}, Channels: outChannels}
+	}
+	s := &Stream{}
+	if err := s.initCallback(p, fun); err != nil {
+		return nil, err
+	}
+	a := &streamAdapter{}
+	in, out := s.callbackBuffers()
+	float32Type := reflect.TypeOf(float32(0))
+	if inChannels > 0 {
+		a.in = newAdapterPath(in, inChannels, float32Type, inChannels, 1, 0)
+	}
+	if outChannels > 0 {
+		a.out = newAdapterPath(out, outChannels, float32Type, outChannels, 1, 0)
+	}
+	return &softCallback{s: s, adapter: a}, nil
+}
+
+// call calls the callback with the given number of interleaved frames of in and out.
+// in or out is ignored if the stream has no input or output channels.
+func (c *softCallback) call(in, out []float32, frames int, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+	if frames == 0 {
+		return
+	}
+	c.s.timeInfo, c.s.flags = timeInfo, flags
+	var inBuf, outBuf unsafe.Pointer
+	if c.adapter.in != nil {
+		inBuf = unsafe.Pointer(&in[0])
+	}
+	if c.adapter.out != nil {
+		outBuf = unsafe.Pointer(&out[0])
+	}
+	c.adapter.callback(c.s, inBuf, outBuf, frames)
+}
@@ -0,0 +1,28 @@
+package portaudio
+
+// Helpers for the float32 buffers that adapters convert and mix samples in.
+
+// resize returns buf with length n, reallocating it without preserving its contents if it is too small.
+func resize(buf []float32, n int) []float32 {
+	if cap(buf) < n {
+		return make([]float32, n)
+	}
+	return buf[:n]
+}
+
+// grow returns buf with length n, preserving its contents.
+func grow(buf []float32, n int) []float32 {
+	if cap(buf) < n {
+		b := make([]float32, len(buf), 2*n)
+		copy(b, buf)
+		buf = b
+	}
+	return buf[:n]
+}
+
+// zero sets the samples of buf to silence.
+func zero(buf []float32) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
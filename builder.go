@@ -0,0 +1,254 @@
+package portaudio
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxLatency is the largest latency, and the longest buffer, accepted by ParametersBuilder.
+const MaxLatency = 10 * time.Second
+
+// A ParameterError describes an invalid field of StreamParameters.
+type ParameterError struct {
+	Field  string // e.g., "Output.Channels"
+	Reason string
+}
+
+func (e *ParameterError) Error() string {
+	return "portaudio: " + e.Field + ": " + e.Reason
+}
+
+// A ParametersBuilder builds and validates StreamParameters.  Its methods may be chained:
+//
+//	p, err := NewParametersBuilder().DefaultOutput().OutputChannels(2).SampleRate(48000).Build()
+//
+// Errors are reported by Build.
+type ParametersBuilder struct {
+	p             StreamParameters
+	lowLatency    bool
+	inLatencySet  bool
+	outLatencySet bool
+	sampleRateSet bool
+	err           error
+}
+
+// NewParametersBuilder returns a ParametersBuilder for a stream with no devices.
+func NewParametersBuilder() *ParametersBuilder {
+	return &ParametersBuilder{}
+}
+
+// Input sets the input device, with stereo input if the device supports it and mono otherwise.
+func (b *ParametersBuilder) Input(dev *DeviceInfo) *ParametersBuilder {
+	b.p.Input.Device = dev
+	if dev != nil {
+		b.p.Input.Channels = defaultChannels(dev.MaxInputChannels)
+	}
+	return b
+}
+
+// Output sets the output device, with stereo output if the device supports it and mono otherwise.
+func (b *ParametersBuilder) Output(dev *DeviceInfo) *ParametersBuilder {
+	b.p.Output.Device = dev
+	if dev != nil {
+		b.p.Output.Channels = defaultChannels(dev.MaxOutputChannels)
+	}
+	return b
+}
+
+func defaultChannels(max int) int {
+	if max < 2 {
+		return max
+	}
+	return 2
+}
+
+// DefaultInput sets the input device to the default input device, as by Input.
+func (b *ParametersBuilder) DefaultInput() *ParametersBuilder {
+	dev, err := DefaultInputDevice()
+	if err != nil {
+		b.setErr(err)
+	}
+	return b.Input(dev)
+}
+
+// DefaultOutput sets the output device to the default output device, as by Output.
+func (b *ParametersBuilder) DefaultOutput() *ParametersBuilder {
+	dev, err := DefaultOutputDevice()
+	if err != nil {
+		b.setErr(err)
+	}
+	return b.Output(dev)
+}
+
+// InputChannels sets the number of input channels.
+func (b *ParametersBuilder) InputChannels(n int) *ParametersBuilder {
+	b.p.Input.Channels = n
+	return b
+}
+
+// OutputChannels sets the number of output channels.
+func (b *ParametersBuilder) OutputChannels(n int) *ParametersBuilder {
+	b.p.Output.Channels = n
+	return b
+}
+
+// InputLatency sets the suggested input latency.
+func (b *ParametersBuilder) InputLatency(d time.Duration) *ParametersBuilder {
+	b.p.Input.Latency = d
+	b.inLatencySet = true
+	return b
+}
+
+// OutputLatency sets the suggested output latency.
+func (b *ParametersBuilder) OutputLatency(d time.Duration) *ParametersBuilder {
+	b.p.Output.Latency = d
+	b.outLatencySet = true
+	return b
+}
+
+// LowLatency selects the devices' default low latencies, where not set explicitly.
+func (b *ParametersBuilder) LowLatency() *ParametersBuilder {
+	b.lowLatency = true
+	return b
+}
+
+// HighLatency selects the devices' default high latencies, where not set explicitly.  This is the default.
+func (b *ParametersBuilder) HighLatency() *ParametersBuilder {
+	b.lowLatency = false
+	return b
+}
+
+// SampleRate sets the sample rate.  By default it is the default sample rate of the
+// output device (or input device, for an input-only stream).
+func (b *ParametersBuilder) SampleRate(r float64) *ParametersBuilder {
+	b.p.SampleRate = r
+	b.sampleRateSet = true
+	return b
+}
+
+// FramesPerBuffer sets the number of frames per buffer.  By default it is FramesPerBufferUnspecified.
+func (b *ParametersBuilder) FramesPerBuffer(n int) *ParametersBuilder {
+	b.p.FramesPerBuffer = n
+	return b
+}
+
+// Flags sets the stream flags.
+func (b *ParametersBuilder) Flags(f StreamFlags) *ParametersBuilder {
+	b.p.Flags = f
+	return b
+}
+
+func (b *ParametersBuilder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build returns the StreamParameters, or the first error encountered while building them
+// or found by validating them.  Validation errors are *ParameterErrors.
+//
+// Build checks that there is a device, that the numbers of channels are within the devices' limits,
+// that latencies are between zero and MaxLatency, that the sample rate is positive, that
+// the buffer is no longer than MaxLatency, and that the flags are known and apply to the stream.
+// Finally it checks that the devices support the parameters with float32 samples (see IsFormatSupported).
+func (b *ParametersBuilder) Build() (StreamParameters, error) {
+	if b.err != nil {
+		return StreamParameters{}, b.err
+	}
+	p := b.p
+	in, out := p.Input.Device, p.Output.Device
+	if in == nil && out == nil {
+		return p, &ParameterError{"Input.Device", "neither an input nor an output device is set"}
+	}
+	if in != nil {
+		if !b.inLatencySet {
+			p.Input.Latency = in.DefaultHighInputLatency
+			if b.lowLatency {
+				p.Input.Latency = in.DefaultLowInputLatency
+			}
+		}
+		if err := validateDevice("Input", p.Input, in.MaxInputChannels); err != nil {
+			return p, err
+		}
+	}
+	if out != nil {
+		if !b.outLatencySet {
+			p.Output.Latency = out.DefaultHighOutputLatency
+			if b.lowLatency {
+				p.Output.Latency = out.DefaultLowOutputLatency
+			}
+		}
+		if err := validateDevice("Output", p.Output, out.MaxOutputChannels); err != nil {
+			return p, err
+		}
+	}
+
+	if !b.sampleRateSet {
+		if out != nil {
+			p.SampleRate = out.DefaultSampleRate
+		} else {
+			p.SampleRate = in.DefaultSampleRate
+		}
+	}
+	if !(p.SampleRate > 0) {
+		return p, &ParameterError{"SampleRate", fmt.Sprintf("%v is not positive", p.SampleRate)}
+	}
+	if p.FramesPerBuffer < 0 {
+		return p, &ParameterError{"FramesPerBuffer", fmt.Sprintf("%d is negative", p.FramesPerBuffer)}
+	}
+	if d := FramesToDuration(int64(p.FramesPerBuffer), p.SampleRate); d > MaxLatency {
+		return p, &ParameterError{"FramesPerBuffer", fmt.Sprintf("%d frames (%v) exceeds MaxLatency", p.FramesPerBuffer, d)}
+	}
+
+	known := ClipOff | DitherOff | NeverDropInput | PrimeOutputBuffersUsingStreamCallback | PlatformSpecificFlags
+	if f := p.Flags &^ known; f != 0 {
+		return p, &ParameterError{"Flags", fmt.Sprintf("unknown flags %#x", f)}
+	}
+	if p.Flags&NeverDropInput != 0 && (in == nil || out == nil || p.FramesPerBuffer != FramesPerBufferUnspecified) {
+		return p, &ParameterError{"Flags", "NeverDropInput requires a full-duplex stream with FramesPerBufferUnspecified"}
+	}
+	if p.Flags&PrimeOutputBuffersUsingStreamCallback != 0 && out == nil {
+		return p, &ParameterError{"Flags", "PrimeOutputBuffersUsingStreamCallback requires an output device"}
+	}
+
+	// Check each direction, and then both, to attribute errors.
+	for _, d := range []struct {
+		field string
+		p     StreamParameters
+	}{
+		{"Input", StreamParameters{Input: p.Input, SampleRate: p.SampleRate}},
+		{"Output", StreamParameters{Output: p.Output, SampleRate: p.SampleRate}},
+		{"Output", p},
+	} {
+		if d.p.Input.Device == nil && d.p.Output.Device == nil {
+			continue
+		}
+		err := IsFormatSupported(d.p, func(in, out []float32) {})
+		if err == nil {
+			continue
+		}
+		field := "SampleRate"
+		switch err {
+		case InvalidChannelCount:
+			field = d.field + ".Channels"
+		case InvalidDevice, DeviceUnavailable, BadIODeviceCombination:
+			field = d.field + ".Device"
+		}
+		return p, &ParameterError{field, fmt.Sprintf("not supported at %v Hz: %v", p.SampleRate, err)}
+	}
+	return p, nil
+}
+
+func validateDevice(field string, p StreamDeviceParameters, maxChannels int) error {
+	switch {
+	case p.Channels < 1:
+		return &ParameterError{field + ".Channels", fmt.Sprintf("%d is less than 1", p.Channels)}
+	case p.Channels > maxChannels:
+		return &ParameterError{field + ".Channels", fmt.Sprintf("device %q has %d channels, not %d", p.Device.Name, maxChannels, p.Channels)}
+	case p.Latency < 0:
+		return &ParameterError{field + ".Latency", fmt.Sprintf("%v is negative", p.Latency)}
+	case p.Latency > MaxLatency:
+		return &ParameterError{field + ".Latency", fmt.Sprintf("%v exceeds MaxLatency", p.Latency)}
+	}
+	return nil
+}
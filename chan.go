@@ -0,0 +1,225 @@
+package portaudio
+
+import (
+	"fmt"
+	"sync/atomic"
+	"unsafe"
+)
+
+// BlockInfo describes a block of input.
+type BlockInfo struct {
+	TimeInfo StreamCallbackTimeInfo
+	Flags    StreamCallbackFlags
+}
+
+// A CaptureChan is an input stream that delivers fixed-size blocks of
+// interleaved samples on a channel.
+//
+// Blocks come from a pool that is allocated when the stream is opened;
+// each received block must be returned with Release once it is no longer needed.
+// If no block is free when input is ready, the input is dropped and counted in Overflows.
+//
+// C is closed when the Stream is closed.
+type CaptureChan[T any] struct {
+	*Stream
+	C         <-chan []T
+	free      chan []T
+	pool      []T
+	samples   int // per block
+	infos     []BlockInfo
+	received  []int32 // accessed atomically; 1 if the block is received from C and not released
+	overflows uint64
+}
+
+// OpenCaptureChan opens an input stream with a pool of the given number of blocks,
+// each holding framesPerBlock frames.  p.Output is ignored.
+// p.FramesPerBuffer may differ from framesPerBlock, or be FramesPerBufferUnspecified.
+func OpenCaptureChan[T any](p StreamParameters, framesPerBlock, blocks int) (*CaptureChan[T], error) {
+	p.Output = StreamDeviceParameters{}
+	if err := checkBlocks(framesPerBlock, blocks, p.Input.Channels); err != nil {
+		return nil, err
+	}
+	full := make(chan []T, blocks)
+	samples := framesPerBlock * p.Input.Channels
+	free, pool := newBlockPool[T](blocks, samples)
+	c := &CaptureChan[T]{C: full, free: free, pool: pool, samples: samples, infos: make([]BlockInfo, blocks), received: make([]int32, blocks)}
+	a, err := NewBlockAdapter(p.SampleRate, framesPerBlock, p.Input.Channels, 0, func(in, _ []T, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+		select {
+		case b := <-c.free:
+			copy(b, in)
+			i, _ := c.index(b)
+			c.infos[i] = BlockInfo{timeInfo, flags}
+			atomic.StoreInt32(&c.received[i], 1)
+			full <- b // never blocks; there are no more blocks than its capacity
+		default:
+			atomic.AddUint64(&c.overflows, 1)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Stream, err = OpenStream(p, a.Callback)
+	if err != nil {
+		return nil, err
+	}
+	c.onClose = append(c.onClose, func() { close(full) })
+	return c, nil
+}
+
+// Release returns a block received from C to the pool.
+// It panics if block is not part of a block received from C, or if it has already been released.
+func (c *CaptureChan[T]) Release(block []T) {
+	i, ok := c.index(block)
+	if !ok || !atomic.CompareAndSwapInt32(&c.received[i], 1, 0) {
+		panic("portaudio: CaptureChan.Release called with a foreign or already released block")
+	}
+	c.free <- c.pool[i*c.samples : (i+1)*c.samples : (i+1)*c.samples] // never blocks; the block was not in the pool
+}
+
+// Info returns the BlockInfo of a block received from C and not yet released.
+// It panics if block is not part of a block from C.
+func (c *CaptureChan[T]) Info(block []T) BlockInfo {
+	i, ok := c.index(block)
+	if !ok {
+		panic("portaudio: CaptureChan.Info called with a foreign block")
+	}
+	return c.infos[i]
+}
+
+// index returns the position in the pool of a block, which may have been resliced,
+// and reports whether the block lies in the pool.
+func (c *CaptureChan[T]) index(block []T) (int, bool) {
+	return poolIndex(c.pool, c.samples, block)
+}
+
+// poolIndex returns the position of block, which may have been resliced, in the pool
+// of blocks of the given number of samples, and reports whether the block lies in the pool.
+func poolIndex[T any](pool []T, samples int, block []T) (int, bool) {
+	if cap(block) == 0 || len(pool) == 0 {
+		return 0, false
+	}
+	size := unsafe.Sizeof(block[:1][0])
+	if size == 0 {
+		return 0, true
+	}
+	offset := uintptr(unsafe.Pointer(&block[:1][0])) - uintptr(unsafe.Pointer(&pool[0]))
+	if offset%size != 0 || offset/size >= uintptr(len(pool)) {
+		// A block below the pool wraps around to a large offset.
+		return 0, false
+	}
+	i := int(offset / size / uintptr(samples))
+	if uintptr(cap(block)) != uintptr((i+1)*samples)-offset/size {
+		// Blocks are capped at their ends, and so are their subslices.
+		return 0, false
+	}
+	return i, true
+}
+
+// Overflows returns the number of blocks of input dropped because no free block was available.
+func (c *CaptureChan[T]) Overflows() uint64 {
+	return atomic.LoadUint64(&c.overflows)
+}
+
+// A PlaybackChan is an output stream that plays fixed-size blocks of
+// interleaved samples sent on a channel.
+//
+// Empty blocks are received from Free, filled, and sent on C; after being played
+// they return to Free.  All blocks are allocated when the stream is opened.
+// If no block has been sent when output is needed, silence is played
+// and counted in Underflows.
+// Blocks sent on C must have been received from Free; others are dropped and counted in Dropped.
+//
+// Free is closed when the Stream is closed; C must not be used thereafter.
+type PlaybackChan[T any] struct {
+	*Stream
+	C          chan<- []T
+	Free       <-chan []T
+	underflows uint64
+	dropped    uint64
+}
+
+// OpenPlaybackChan opens an output stream with a pool of the given number of blocks,
+// each holding framesPerBlock frames.  p.Input is ignored.
+// p.FramesPerBuffer may differ from framesPerBlock, or be FramesPerBufferUnspecified.
+func OpenPlaybackChan[T any](p StreamParameters, framesPerBlock, blocks int) (*PlaybackChan[T], error) {
+	p.Input = StreamDeviceParameters{}
+	if err := checkBlocks(framesPerBlock, blocks, p.Output.Channels); err != nil {
+		return nil, err
+	}
+	full := make(chan []T, blocks)
+	samples := framesPerBlock * p.Output.Channels
+	free, pool := newBlockPool[T](blocks, samples)
+	c := &PlaybackChan[T]{C: full, Free: free}
+	a, err := NewBlockAdapter(p.SampleRate, framesPerBlock, 0, p.Output.Channels, func(_, out []T, _ StreamCallbackTimeInfo, _ StreamCallbackFlags) {
+		select {
+		case b := <-full:
+			k, ok := poolIndex(pool, samples, b)
+			if !ok {
+				atomic.AddUint64(&c.dropped, 1)
+				b = nil
+			}
+			n := copy(out, b)
+			var zero T
+			for i := range out[n:] {
+				out[n+i] = zero
+			}
+			if ok {
+				select {
+				case free <- pool[k*samples : (k+1)*samples : (k+1)*samples]:
+				default:
+					// The block was sent more than once, so it is already in free.
+					atomic.AddUint64(&c.dropped, 1)
+				}
+			}
+		default:
+			var zero T
+			for i := range out {
+				out[i] = zero
+			}
+			atomic.AddUint64(&c.underflows, 1)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	c.Stream, err = OpenStream(p, a.Callback)
+	if err != nil {
+		return nil, err
+	}
+	c.onClose = append(c.onClose, func() { close(free) })
+	return c, nil
+}
+
+// Underflows returns the number of blocks of silence played because no block had been sent.
+func (c *PlaybackChan[T]) Underflows() uint64 {
+	return atomic.LoadUint64(&c.underflows)
+}
+
+// Dropped returns the number of misused blocks sent on C:  blocks not received from Free,
+// which are replaced by silence, and blocks sent again before returning to Free.
+func (c *PlaybackChan[T]) Dropped() uint64 {
+	return atomic.LoadUint64(&c.dropped)
+}
+
+// checkBlocks returns an error if a CaptureChan or PlaybackChan can't have the given block sizes.
+func checkBlocks(framesPerBlock, blocks, channels int) error {
+	switch {
+	case framesPerBlock < 1:
+		return fmt.Errorf("portaudio: invalid number of frames per block %d", framesPerBlock)
+	case blocks < 1:
+		return fmt.Errorf("portaudio: invalid number of blocks %d", blocks)
+	case channels < 1:
+		return fmt.Errorf("portaudio: invalid number of channels %d", channels)
+	}
+	return nil
+}
+
+// newBlockPool returns a channel holding the given number of blocks and the buffer they share.
+func newBlockPool[T any](blocks, samples int) (chan []T, []T) {
+	pool := make(chan []T, blocks)
+	buf := make([]T, blocks*samples)
+	for i := 0; i < blocks; i++ {
+		pool <- buf[i*samples : (i+1)*samples : (i+1)*samples]
+	}
+	return pool, buf
+}
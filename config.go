@@ -0,0 +1,186 @@
+package portaudio
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A StreamConfig is a serializable description of StreamParameters, e.g., for a configuration file.
+// It encodes to and decodes from JSON; see also ApplyEnv.
+type StreamConfig struct {
+	Input  DeviceConfig `json:"input,omitempty"`
+	Output DeviceConfig `json:"output,omitempty"`
+
+	// SampleRate defaults to the default sample rate of the output device (or input device, for an input-only stream).
+	SampleRate float64 `json:"sampleRate,omitempty"`
+
+	// FramesPerBuffer defaults to FramesPerBufferUnspecified.
+	FramesPerBuffer int `json:"framesPerBuffer,omitempty"`
+}
+
+// A DeviceConfig describes the input or output of a StreamConfig.
+type DeviceConfig struct {
+	// Device is a device ID (see DeviceByID) or a name to search for (see Query.NameContains).
+	// Empty or "default" selects the default device.
+	Device string `json:"device,omitempty"`
+
+	// Channels is the number of channels.  Zero disables the input or output.
+	Channels int `json:"channels,omitempty"`
+
+	// Latency is "low" or "high" (the default) for the device's default low or high latency,
+	// or a duration such as "20ms".
+	Latency string `json:"latency,omitempty"`
+}
+
+// ApplyEnv overrides c with the environment variables that are set among
+//
+//	PREFIX_INPUT_DEVICE, PREFIX_INPUT_CHANNELS, PREFIX_INPUT_LATENCY,
+//	PREFIX_OUTPUT_DEVICE, PREFIX_OUTPUT_CHANNELS, PREFIX_OUTPUT_LATENCY,
+//	PREFIX_SAMPLE_RATE, and PREFIX_FRAMES_PER_BUFFER,
+//
+// where PREFIX is the given prefix, e.g., "PORTAUDIO".
+func (c *StreamConfig) ApplyEnv(prefix string) error {
+	env := func(name string) (string, bool) {
+		return os.LookupEnv(prefix + "_" + name)
+	}
+	for _, d := range []struct {
+		name string
+		c    *DeviceConfig
+	}{{"INPUT", &c.Input}, {"OUTPUT", &c.Output}} {
+		if v, ok := env(d.name + "_DEVICE"); ok {
+			d.c.Device = v
+		}
+		if v, ok := env(d.name + "_CHANNELS"); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("portaudio: %s_%s_CHANNELS: %v", prefix, d.name, err)
+			}
+			d.c.Channels = n
+		}
+		if v, ok := env(d.name + "_LATENCY"); ok {
+			d.c.Latency = v
+		}
+	}
+	if v, ok := env("SAMPLE_RATE"); ok {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("portaudio: %s_SAMPLE_RATE: %v", prefix, err)
+		}
+		c.SampleRate = r
+	}
+	if v, ok := env("FRAMES_PER_BUFFER"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("portaudio: %s_FRAMES_PER_BUFFER: %v", prefix, err)
+		}
+		c.FramesPerBuffer = n
+	}
+	return nil
+}
+
+// StreamParameters resolves the devices of c and returns the StreamParameters it describes.
+// It returns an error if a device cannot be found, if c exceeds a device's limits,
+// or if the devices do not support the sample rate (see IsFormatSupported).
+func (c StreamConfig) StreamParameters() (StreamParameters, error) {
+	p := StreamParameters{SampleRate: c.SampleRate, FramesPerBuffer: c.FramesPerBuffer}
+	if c.Input.Channels == 0 && c.Output.Channels == 0 {
+		return p, fmt.Errorf("portaudio: stream config has neither input nor output channels")
+	}
+	var err error
+	if p.Input, err = c.Input.resolve(true); err != nil {
+		return p, err
+	}
+	if p.Output, err = c.Output.resolve(false); err != nil {
+		return p, err
+	}
+	if p.SampleRate < 0 {
+		return p, fmt.Errorf("portaudio: invalid sample rate %v", p.SampleRate)
+	}
+	if p.SampleRate == 0 {
+		if p.Output.Device != nil {
+			p.SampleRate = p.Output.Device.DefaultSampleRate
+		} else {
+			p.SampleRate = p.Input.Device.DefaultSampleRate
+		}
+	}
+	if p.FramesPerBuffer < 0 {
+		return p, fmt.Errorf("portaudio: invalid frames per buffer %d", p.FramesPerBuffer)
+	}
+	if err := IsFormatSupported(p, func(in, out []float32) {}); err != nil {
+		return p, fmt.Errorf("portaudio: sample rate %v: %w", p.SampleRate, err)
+	}
+	return p, nil
+}
+
+func (c DeviceConfig) resolve(input bool) (StreamDeviceParameters, error) {
+	var p StreamDeviceParameters
+	if c.Channels == 0 {
+		return p, nil
+	}
+	dir := "output"
+	if input {
+		dir = "input"
+	}
+	if c.Channels < 0 {
+		return p, fmt.Errorf("portaudio: invalid number of %s channels %d", dir, c.Channels)
+	}
+
+	var dev *DeviceInfo
+	var err error
+	switch {
+	case c.Device == "" || c.Device == "default":
+		if input {
+			dev, err = DefaultInputDevice()
+		} else {
+			dev, err = DefaultOutputDevice()
+		}
+	default:
+		dev, err = DeviceByID(c.Device)
+		if err == ErrDeviceNotFound {
+			q := Query{NameContains: c.Device}
+			if input {
+				q.MinInputChannels = 1
+			} else {
+				q.MinOutputChannels = 1
+			}
+			var matches []DeviceMatch
+			if matches, err = FindDevices(q); err == nil {
+				if len(matches) == 0 {
+					return p, fmt.Errorf("portaudio: no %s device matches %q", dir, c.Device)
+				}
+				dev = matches[0].Device
+			}
+		}
+	}
+	if err != nil {
+		return p, err
+	}
+
+	max := dev.MaxOutputChannels
+	if input {
+		max = dev.MaxInputChannels
+	}
+	if c.Channels > max {
+		return p, fmt.Errorf("portaudio: %s device %q has %d channels, not %d", dir, dev.Name, max, c.Channels)
+	}
+
+	low, high := dev.DefaultLowOutputLatency, dev.DefaultHighOutputLatency
+	if input {
+		low, high = dev.DefaultLowInputLatency, dev.DefaultHighInputLatency
+	}
+	var latency time.Duration
+	switch strings.ToLower(c.Latency) {
+	case "", "high":
+		latency = high
+	case "low":
+		latency = low
+	default:
+		if latency, err = time.ParseDuration(c.Latency); err != nil || latency < 0 {
+			return p, fmt.Errorf("portaudio: invalid %s latency %q", dir, c.Latency)
+		}
+	}
+	return StreamDeviceParameters{Device: dev, Channels: c.Channels, Latency: latency}, nil
+}
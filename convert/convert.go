@@ -0,0 +1,270 @@
+/*
+Package convert converts audio samples between the sample types of portaudio Buffers:
+float32, int32, portaudio.Int24, int16, int8, and uint8 (offset binary).
+
+Conversions follow PortAudio's own converters.  Integer samples are scaled to floats by 1/2^(bits-1)
+and floats are scaled to integers by 2^(bits-1)-1.  Integers are widened by shifting left.
+Narrowing conversions (floats to integers and wide to narrow integers) are quantized according
+to the Options of a Converter:  the fractional part is truncated or rounded, optionally after adding
+triangular (TPDF) dither and first-order noise shaping.  Results are always clipped to the range of
+the destination type.  Float-to-float conversions are plain copies; float outputs are not clipped.
+
+The conversion functions do not allocate, so they may be called from a portaudio StreamCallback.
+*/
+package convert
+
+import (
+	"math"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// Sample is the set of sample types of portaudio Buffers.
+type Sample interface {
+	float32 | int32 | portaudio.Int24 | int16 | int8 | uint8
+}
+
+// Rounding determines how the fractional part of a sample is discarded when it is quantized.
+type Rounding int
+
+const (
+	// Truncate discards the fractional part as PortAudio's converters do:
+	// toward zero for float sources and toward negative infinity
+	// (i.e., an arithmetic shift) for integer sources.
+	Truncate Rounding = iota
+
+	// Nearest rounds to the nearest integer, with halves rounded up.
+	Nearest
+)
+
+// Options configure the quantization of narrowing conversions.
+type Options struct {
+	Rounding Rounding
+
+	// Dither adds triangular noise with an amplitude of ±1 LSB of the destination before quantizing.
+	Dither bool
+
+	// NoiseShaping feeds the quantization error of each sample back into the next sample
+	// of the same channel, moving the quantization noise toward high frequencies.
+	NoiseShaping bool
+}
+
+// A Converter holds Options and the per-channel dither and noise shaping state for a stream of samples.
+// A nil *Converter is valid and truncates without dither or noise shaping, which needs no state.
+//
+// A Converter should be used for only one stream of samples at a time.
+type Converter struct {
+	Options
+	channels     int
+	seed1, seed2 uint32
+	err          []float64
+}
+
+// NewConverter returns a Converter for a stream with the given number of channels.
+func NewConverter(channels int, opts Options) *Converter {
+	if channels < 1 {
+		channels = 1
+	}
+	return &Converter{
+		Options:  opts,
+		channels: channels,
+		seed1:    22222,
+		seed2:    5555555,
+		err:      make([]float64, channels),
+	}
+}
+
+// Channels returns the number of channels of c.
+func (c *Converter) Channels() int {
+	if c == nil {
+		return 1
+	}
+	return c.channels
+}
+
+// Reset clears the noise shaping state of c, e.g., after a discontinuity in the stream.
+func (c *Converter) Reset() {
+	if c == nil {
+		return
+	}
+	for i := range c.err {
+		c.err[i] = 0
+	}
+}
+
+// Interleaved converts the interleaved samples in src to dst and
+// returns the number of samples converted, min(len(dst), len(src)).
+// Both slices must start at the beginning of a frame.
+func Interleaved[D, S Sample](c *Converter, dst []D, src []S) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	c.convert(dst[:n], src[:n], c.Channels(), 0)
+	return n
+}
+
+// NonInterleaved converts the non-interleaved channels in src to dst and returns
+// the number of frames converted, the minimum length of any converted channel.
+// min(len(dst), len(src)) channels are converted.
+func NonInterleaved[D, S Sample](c *Converter, dst [][]D, src [][]S) int {
+	channels := len(src)
+	if len(dst) < channels {
+		channels = len(dst)
+	}
+	if channels == 0 {
+		return 0
+	}
+	frames := len(src[0])
+	for i := 0; i < channels; i++ {
+		if len(src[i]) < frames {
+			frames = len(src[i])
+		}
+		if len(dst[i]) < frames {
+			frames = len(dst[i])
+		}
+	}
+	for i := 0; i < channels; i++ {
+		c.convert(dst[i][:frames], src[i][:frames], 1, i%c.Channels())
+	}
+	return frames
+}
+
+// convert converts len(src) samples from src to dst, both of which are slices of Samples.
+// Sample i belongs to channel channel0 + i%channels.
+func (c *Converter) convert(dst, src interface{}, channels, channel0 int) {
+	// Read the source either as floats or as left-justified int32s.
+	var (
+		f       []float32
+		get     func(i int) int32
+		n       int
+		srcBits int
+	)
+	switch s := src.(type) {
+	case []float32:
+		f, n = s, len(s)
+	case []int32:
+		get, n, srcBits = func(i int) int32 { return s[i] }, len(s), 32
+	case []portaudio.Int24:
+		get, n, srcBits = func(i int) int32 { return s[i].Int32() }, len(s), 24
+	case []int16:
+		get, n, srcBits = func(i int) int32 { return int32(s[i]) << 16 }, len(s), 16
+	case []int8:
+		get, n, srcBits = func(i int) int32 { return int32(s[i]) << 24 }, len(s), 8
+	case []uint8:
+		get, n, srcBits = func(i int) int32 { return (int32(s[i]) - 128) << 24 }, len(s), 8
+	}
+
+	var (
+		put  func(i int, x int32)
+		bits int
+	)
+	switch d := dst.(type) {
+	case []float32:
+		if f != nil {
+			copy(d, f)
+			return
+		}
+		for i := range d {
+			d[i] = float32(float64(get(i)) * (1.0 / (1 << 31)))
+		}
+		return
+	case []int32:
+		put, bits = func(i int, x int32) { d[i] = x }, 32
+	case []portaudio.Int24:
+		put, bits = func(i int, x int32) { d[i].PutInt32(x << 8) }, 24
+	case []int16:
+		put, bits = func(i int, x int32) { d[i] = int16(x) }, 16
+	case []int8:
+		put, bits = func(i int, x int32) { d[i] = int8(x) }, 8
+	case []uint8:
+		put, bits = func(i int, x int32) { d[i] = uint8(x + 128) }, 8
+	}
+
+	shift := uint(32 - bits)
+	if f == nil && (srcBits <= bits || c.plain()) {
+		// Exact, or truncation by an arithmetic shift.
+		for i := 0; i < n; i++ {
+			put(i, get(i)>>shift)
+		}
+		return
+	}
+
+	q := quantizer{
+		c:     c,
+		min:   -float64(int64(1) << (bits - 1)),
+		max:   float64(int64(1)<<(bits-1)) - 1,
+		float: f != nil,
+	}
+	ch := channel0
+	if f != nil {
+		scale := q.max
+		for i := 0; i < n; i++ {
+			put(i, q.quantize(float64(f[i])*scale, ch))
+			if ch++; ch == channel0+channels {
+				ch = channel0
+			}
+		}
+		return
+	}
+	scale := 1 / float64(int64(1)<<shift)
+	for i := 0; i < n; i++ {
+		put(i, q.quantize(float64(get(i))*scale, ch))
+		if ch++; ch == channel0+channels {
+			ch = channel0
+		}
+	}
+}
+
+// plain reports whether c truncates without dither or noise shaping.
+func (c *Converter) plain() bool {
+	return c == nil || c.Rounding == Truncate && !c.Dither && !c.NoiseShaping
+}
+
+type quantizer struct {
+	c        *Converter
+	min, max float64
+	float    bool
+}
+
+// quantize quantizes x, in units of the destination's LSB, for the given channel.
+func (q quantizer) quantize(x float64, channel int) int32 {
+	c := q.c
+	if x != x {
+		x = 0
+	}
+	if c != nil && c.NoiseShaping {
+		x -= c.err[channel]
+	}
+	y := x
+	if c != nil && c.Dither {
+		y += c.triangularDither()
+	}
+	switch {
+	case c != nil && c.Rounding == Nearest:
+		y = math.Floor(y + .5)
+	case q.float:
+		y = math.Trunc(y)
+	default:
+		y = math.Floor(y)
+	}
+	if c != nil && c.NoiseShaping {
+		// Limit the fed back error so that clipping can't make the loop unstable.
+		c.err[channel] = math.Max(-2, math.Min(2, y-x))
+	}
+	switch {
+	case y < q.min:
+		y = q.min
+	case y > q.max:
+		y = q.max
+	}
+	return int32(y)
+}
+
+// triangularDither returns triangular noise in the range (-1, 1),
+// using the same generator as PortAudio.
+func (c *Converter) triangularDither() float64 {
+	c.seed1 = c.seed1*196314165 + 907633515
+	c.seed2 = c.seed2*196314165 + 907633515
+	return (float64(c.seed1)+float64(c.seed2))/(1<<32) - 1
+}
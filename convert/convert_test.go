@@ -0,0 +1,150 @@
+package convert
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+func int24(x int32) (i24 portaudio.Int24) {
+	i24.PutInt32(x << 8)
+	return i24
+}
+
+// floats are the sources for TestFromFloat32:  full scale, beyond full scale, and NaN.
+var floats = []float32{1, -1, 2, -2, float32(math.Inf(1)), float32(math.Inf(-1)), float32(math.NaN()), 0}
+
+func TestFromFloat32(t *testing.T) {
+	testFromFloat32(t, []int32{math.MaxInt32, -math.MaxInt32, math.MaxInt32, math.MinInt32, math.MaxInt32, math.MinInt32, 0, 0})
+	testFromFloat32(t, []portaudio.Int24{int24(1<<23 - 1), int24(-(1<<23 - 1)), int24(1<<23 - 1), int24(-1 << 23), int24(1<<23 - 1), int24(-1 << 23), int24(0), int24(0)})
+	testFromFloat32(t, []int16{math.MaxInt16, -math.MaxInt16, math.MaxInt16, math.MinInt16, math.MaxInt16, math.MinInt16, 0, 0})
+	testFromFloat32(t, []int8{math.MaxInt8, -math.MaxInt8, math.MaxInt8, math.MinInt8, math.MaxInt8, math.MinInt8, 0, 0})
+	testFromFloat32(t, []uint8{255, 1, 255, 0, 255, 0, 128, 128})
+}
+
+func testFromFloat32[D Sample](t *testing.T, want []D) {
+	t.Helper()
+	for _, opts := range []Options{{Rounding: Truncate}, {Rounding: Nearest}} {
+		got := make([]D, len(floats))
+		if n := Interleaved(NewConverter(1, opts), got, floats); n != len(floats) {
+			t.Errorf("%T: converted %d samples, want %d", got, n, len(floats))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%T with %+v: %v converted to %v, want %v", got, opts, floats[i], got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestToFloat32(t *testing.T) {
+	testToFloat32(t, []int32{math.MaxInt32, math.MinInt32, 1 << 30}, []float32{1 - 1.0/(1<<31), -1, .5})
+	testToFloat32(t, []portaudio.Int24{int24(1<<23 - 1), int24(-1 << 23), int24(1 << 22)}, []float32{1 - 1.0/(1<<23), -1, .5})
+	testToFloat32(t, []int16{math.MaxInt16, math.MinInt16, 1 << 14}, []float32{1 - 1.0/(1<<15), -1, .5})
+	testToFloat32(t, []int8{math.MaxInt8, math.MinInt8, 1 << 6}, []float32{1 - 1.0/(1<<7), -1, .5})
+	testToFloat32(t, []uint8{255, 0, 192, 128}, []float32{1 - 1.0/(1<<7), -1, .5, 0})
+}
+
+func testToFloat32[S Sample](t *testing.T, src []S, want []float32) {
+	t.Helper()
+	got := make([]float32, len(src))
+	Interleaved(nil, got, src)
+	for i := range got {
+		// The int32 maximum is not representable as a float32.
+		if math.Abs(float64(got[i]-want[i])) > 1.0/(1<<24) {
+			t.Errorf("%T: %v converted to %v, want %v", src, src[i], got[i], want[i])
+		}
+	}
+}
+
+func TestNarrowingRounding(t *testing.T) {
+	src := []int32{math.MaxInt32, math.MinInt32, 0x7fff8000, 0x12348000, 0x12347fff, -0x12348000, -0x12348001}
+	for _, test := range []struct {
+		rounding Rounding
+		want     []int16
+	}{
+		{Truncate, []int16{math.MaxInt16, math.MinInt16, 0x7fff, 0x1234, 0x1234, -0x1235, -0x1235}},
+		{Nearest, []int16{math.MaxInt16, math.MinInt16, math.MaxInt16, 0x1235, 0x1234, -0x1234, -0x1235}},
+	} {
+		got := make([]int16, len(src))
+		Interleaved(NewConverter(1, Options{Rounding: test.rounding}), got, src)
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("rounding %d: %#x converted to %#x, want %#x", test.rounding, src[i], got[i], test.want[i])
+			}
+		}
+	}
+}
+
+func TestOffsetBinary(t *testing.T) {
+	src := []int8{math.MinInt8, -1, 0, 1, math.MaxInt8}
+	want := []uint8{0, 127, 128, 129, 255}
+	got := make([]uint8, len(src))
+	Interleaved(nil, got, src)
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%d converted to %d, want %d", src[i], got[i], want[i])
+		}
+	}
+
+	back := make([]int8, len(got))
+	Interleaved(nil, back, got)
+	for i := range back {
+		if back[i] != src[i] {
+			t.Errorf("%d converted to %d, want %d", got[i], back[i], src[i])
+		}
+	}
+
+	wide := make([]int16, len(got))
+	Interleaved(nil, wide, got)
+	for i := range wide {
+		if want := int16(src[i]) << 8; wide[i] != want {
+			t.Errorf("%d converted to %d, want %d", got[i], wide[i], want)
+		}
+	}
+}
+
+func TestNonInterleaved(t *testing.T) {
+	src := [][]float32{{.5, 1}, {-.5, -1, 0}}
+	dst := [][]int16{make([]int16, 3), make([]int16, 3)}
+	if n := NonInterleaved(NewConverter(2, Options{Rounding: Nearest}), dst, src); n != 2 {
+		t.Fatalf("converted %d frames, want 2", n)
+	}
+	want := [][]int16{{16384, math.MaxInt16, 0}, {-16383, -math.MaxInt16, 0}}
+	for i := range want {
+		for j := range want[i] {
+			if dst[i][j] != want[i][j] {
+				t.Errorf("channel %d sample %d = %d, want %d", i, j, dst[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestNoAllocs(t *testing.T) {
+	const channels = 2
+	f := make([]float32, 256*channels)
+	for i := range f {
+		f[i] = float32(math.Sin(float64(i)))
+	}
+	i32 := make([]int32, len(f))
+	i24 := make([]portaudio.Int24, len(f))
+	i16 := make([]int16, len(f))
+	u8 := make([]uint8, len(f))
+	nonInterleaved := [][]float32{f[:256], f[256:]}
+	nonInterleaved16 := [][]int16{i16[:256], i16[256:]}
+	for _, opts := range []Options{{}, {Rounding: Nearest, Dither: true, NoiseShaping: true}} {
+		c := NewConverter(channels, opts)
+		allocs := testing.AllocsPerRun(10, func() {
+			Interleaved(c, i32, f)
+			Interleaved(c, i24, i32)
+			Interleaved(c, i16, i24)
+			Interleaved(c, u8, i16)
+			Interleaved(c, f, u8)
+			NonInterleaved(c, nonInterleaved16, nonInterleaved)
+		})
+		if allocs != 0 {
+			t.Errorf("with %+v: %v allocations per run, want 0", opts, allocs)
+		}
+	}
+}
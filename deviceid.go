@@ -0,0 +1,63 @@
+package portaudio
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrDeviceNotFound is returned by DeviceByID when no device has the given ID.
+var ErrDeviceNotFound = errors.New("portaudio: device not found")
+
+// assignDeviceIDs sets the ID of each device to the form
+//
+//	HostApiType/Name/InputsxOutputs
+//
+// (e.g., "CoreAudio/MacBook Pro Speakers/0x2") with a suffix of "#2", "#3", etc.
+// for the second and later devices, in index order, whose IDs would otherwise be the same.
+func assignDeviceIDs(devs []*DeviceInfo) {
+	seen := map[string]int{}
+	for _, d := range devs {
+		id := deviceKey(d)
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id = fmt.Sprintf("%s#%d", id, n)
+		}
+		d.ID = id
+	}
+}
+
+func deviceKey(d *DeviceInfo) string {
+	api := ""
+	if d.HostApi != nil {
+		api = d.HostApi.Type.String()
+	}
+	return fmt.Sprintf("%s/%s/%dx%d", api, d.Name, d.MaxInputChannels, d.MaxOutputChannels)
+}
+
+// DeviceByID returns the device with the given ID (see DeviceInfo.ID).
+//
+// If there is none, but there are devices whose IDs differ only in the suffix that
+// disambiguates duplicates (e.g., because one of two identical devices was unplugged),
+// it returns the first of them.
+func DeviceByID(id string) (*DeviceInfo, error) {
+	devs, err := Devices()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range devs {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	key := id
+	if i := strings.LastIndex(id, "#"); i > strings.LastIndex(id, "/") {
+		key = id[:i]
+	}
+	for _, d := range devs {
+		if deviceKey(d) == key {
+			return d, nil
+		}
+	}
+	return nil, ErrDeviceNotFound
+}
@@ -0,0 +1,136 @@
+package portaudio
+
+import (
+	"math"
+	"time"
+)
+
+// A DriftEstimator estimates the ratio between the actual sample rates of two
+// streams, whose devices are driven by independent clocks.
+//
+// Call Update periodically, e.g., every 100 milliseconds, from an ordinary goroutine
+// while both streams are running.  Each Update samples each stream's frame position
+// (see OutputPosition and InputPosition) and Time; a linear regression over
+// the most recent samples gives each stream's actual frame rate.
+//
+// A DriftEstimator is not safe for concurrent use.
+type DriftEstimator struct {
+	a, b    *Stream
+	samples []driftSample // circular
+	next, n int
+	t0      time.Duration
+}
+
+type driftSample struct {
+	ta, tb float64 // seconds since t0
+	fa, fb float64
+}
+
+// DriftEstimate is the result of a DriftEstimator.
+type DriftEstimate struct {
+	// RateA and RateB are the measured frame rates of the two streams, in frames per second of stream time.
+	RateA, RateB float64
+
+	// Ratio is RateB / RateA, the number of frames of stream b per frame of stream a.
+	// To pass audio from a to b, resample by this ratio.
+	Ratio float64
+
+	// Drift is Ratio relative to the ratio of the streams' nominal sample rates, minus one.
+	// It is positive when b's clock runs fast relative to a's.
+	Drift float64
+
+	// StdErr is the standard error of Ratio.
+	StdErr float64
+
+	// Confidence is in [0, 1]:  1 / (1 + StdErr/Ratio * 1e6), so it is 0.5 when the
+	// relative uncertainty is one part per million, and approaches 1 as it shrinks.
+	// It is 0 until enough samples have been gathered.
+	Confidence float64
+}
+
+// NewDriftEstimator returns a DriftEstimator comparing streams a and b,
+// using a window of the given number of most recent samples.
+func NewDriftEstimator(a, b *Stream, window int) *DriftEstimator {
+	if window < 3 {
+		window = 3
+	}
+	return &DriftEstimator{a: a, b: b, samples: make([]driftSample, window)}
+}
+
+// Reset discards all samples, e.g., after either stream is restarted.
+func (d *DriftEstimator) Reset() {
+	d.next, d.n = 0, 0
+}
+
+// Update samples the positions and times of both streams.
+func (d *DriftEstimator) Update() {
+	fa, ta := d.a.position(), d.a.Time()
+	fb, tb := d.b.position(), d.b.Time()
+	if d.n == 0 {
+		d.t0 = ta
+	}
+	d.samples[d.next] = driftSample{(ta - d.t0).Seconds(), (tb - d.t0).Seconds(), float64(fa), float64(fb)}
+	d.next = (d.next + 1) % len(d.samples)
+	if d.n < len(d.samples) {
+		d.n++
+	}
+}
+
+// position returns the output position of s, or its input position if it has no output.
+func (s *Stream) position() int64 {
+	if s.out != nil {
+		return s.OutputPosition()
+	}
+	return s.InputPosition()
+}
+
+// Estimate returns the current estimate.
+func (d *DriftEstimator) Estimate() DriftEstimate {
+	if d.n < 3 {
+		return DriftEstimate{}
+	}
+	s := d.samples[:d.n]
+	rateA, varA := regress(s, func(s driftSample) (float64, float64) { return s.ta, s.fa })
+	rateB, varB := regress(s, func(s driftSample) (float64, float64) { return s.tb, s.fb })
+	if rateA == 0 || rateB == 0 {
+		return DriftEstimate{}
+	}
+	e := DriftEstimate{RateA: rateA, RateB: rateB, Ratio: rateB / rateA}
+	e.StdErr = e.Ratio * math.Sqrt(varA/(rateA*rateA)+varB/(rateB*rateB))
+	if ia, ib := d.a.Info(), d.b.Info(); ia != nil && ib != nil && ia.SampleRate > 0 {
+		e.Drift = e.Ratio/(ib.SampleRate/ia.SampleRate) - 1
+	}
+	e.Confidence = 1 / (1 + e.StdErr/e.Ratio*1e6)
+	return e
+}
+
+// regress returns the slope of the least-squares line through the points
+// and the variance of that slope.
+func regress(samples []driftSample, xy func(driftSample) (float64, float64)) (slope, variance float64) {
+	n := float64(len(samples))
+	var mx, my float64
+	for _, s := range samples {
+		x, y := xy(s)
+		mx += x
+		my += y
+	}
+	mx /= n
+	my /= n
+	var sxx, sxy float64
+	for _, s := range samples {
+		x, y := xy(s)
+		sxx += (x - mx) * (x - mx)
+		sxy += (x - mx) * (y - my)
+	}
+	if sxx == 0 {
+		return 0, 0
+	}
+	slope = sxy / sxx
+	var ssr float64
+	for _, s := range samples {
+		x, y := xy(s)
+		r := y - my - slope*(x-mx)
+		ssr += r * r
+	}
+	return slope, ssr / (n - 2) / sxx
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"github.com/gordonklaus/portaudio"
-	"encoding/binary"
+	"github.com/gordonklaus/portaudio/aiff"
 	"fmt"
 	"os"
 	"os/signal"
@@ -25,44 +25,13 @@ func main() {
 	}
 	f, err := os.Create(fileName)
 	chk(err)
+	defer f.Close()
 
-	// form chunk
-	_, err = f.WriteString("FORM")
+	e, err := aiff.NewEncoder(f, aiff.Format{SampleRate: 44100, Channels: 1, BitsPerSample: 32})
 	chk(err)
-	chk(binary.Write(f, binary.BigEndian, int32(0))) //total bytes
-	_, err = f.WriteString("AIFF")
-	chk(err)
-
-	// common chunk
-	_, err = f.WriteString("COMM")
-	chk(err)
-	chk(binary.Write(f, binary.BigEndian, int32(18)))                  //size
-	chk(binary.Write(f, binary.BigEndian, int16(1)))                   //channels
-	chk(binary.Write(f, binary.BigEndian, int32(0)))                   //number of samples
-	chk(binary.Write(f, binary.BigEndian, int16(32)))                  //bits per sample
-	_, err = f.Write([]byte{0x40, 0x0e, 0xac, 0x44, 0, 0, 0, 0, 0, 0}) //80-bit sample rate 44100
-	chk(err)
-
-	// sound chunk
-	_, err = f.WriteString("SSND")
-	chk(err)
-	chk(binary.Write(f, binary.BigEndian, int32(0))) //size
-	chk(binary.Write(f, binary.BigEndian, int32(0))) //offset
-	chk(binary.Write(f, binary.BigEndian, int32(0))) //block
-	nSamples := 0
 	defer func() {
-		// fill in missing sizes
-		totalBytes := 4 + 8 + 18 + 8 + 8 + 4*nSamples
-		_, err = f.Seek(4, 0)
-		chk(err)
-		chk(binary.Write(f, binary.BigEndian, int32(totalBytes)))
-		_, err = f.Seek(22, 0)
-		chk(err)
-		chk(binary.Write(f, binary.BigEndian, int32(nSamples)))
-		_, err = f.Seek(42, 0)
-		chk(err)
-		chk(binary.Write(f, binary.BigEndian, int32(4*nSamples+8)))
-		chk(f.Close())
+		// fill in the sizes in the header
+		chk(e.Close())
 	}()
 
 	portaudio.Initialize()
@@ -75,8 +44,8 @@ func main() {
 	chk(stream.Start())
 	for {
 		chk(stream.Read())
-		chk(binary.Write(f, binary.BigEndian, in))
-		nSamples += len(in)
+		_, err := e.Write(in)
+		chk(err)
 		select {
 		case <-sig:
 			return
@@ -0,0 +1,188 @@
+package portaudio
+
+/*
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// A Query selects devices for FindDevice and FindDevices.  Zero fields match any device.
+type Query struct {
+	// NameContains is matched against device names, ignoring case, punctuation, and spacing,
+	// and tolerating a few typos.  Names that contain it exactly rank higher.
+	NameContains string
+
+	// NameRegexp, if not empty, is a regular expression that device names must match.
+	NameRegexp string
+
+	// HostApi, if not InDevelopment (the zero value), is the host API of the device.
+	HostApi HostApiType
+
+	MinInputChannels, MinOutputChannels int
+
+	// SampleRate, if nonzero, is a sample rate that the device must support (see IsFormatSupported)
+	// with float32 samples and the minimum numbers of channels (or one channel).
+	SampleRate float64
+}
+
+// A DeviceMatch is a device found by FindDevices, with a score from 0 to 1 of how well its name matches.
+type DeviceMatch struct {
+	Device *DeviceInfo
+	Score  float64
+}
+
+// FindDevices returns the devices that match q, best first.  Ties are ordered with
+// default devices first and then in the order of Devices.
+func FindDevices(q Query) ([]DeviceMatch, error) {
+	devs, err := Devices()
+	if err != nil {
+		return nil, err
+	}
+	var re *regexp.Regexp
+	if q.NameRegexp != "" {
+		if re, err = regexp.Compile(q.NameRegexp); err != nil {
+			return nil, err
+		}
+	}
+	var matches []DeviceMatch
+	for _, d := range devs {
+		if re != nil && !re.MatchString(d.Name) ||
+			q.HostApi != InDevelopment && (d.HostApi == nil || d.HostApi.Type != q.HostApi) ||
+			d.MaxInputChannels < q.MinInputChannels || d.MaxOutputChannels < q.MinOutputChannels ||
+			q.SampleRate != 0 && !supportsSampleRate(d, q) {
+			continue
+		}
+		score := 1.0
+		if q.NameContains != "" {
+			score = matchName(d.Name, q.NameContains)
+		}
+		if score > 0 {
+			matches = append(matches, DeviceMatch{d, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		mi, mj := matches[i], matches[j]
+		if mi.Score != mj.Score {
+			return mi.Score > mj.Score
+		}
+		return isDefault(mi.Device) && !isDefault(mj.Device)
+	})
+	return matches, nil
+}
+
+// FindDevice returns the device that best matches q.  If none does, it returns the default
+// input device if q requires input channels but not output channels, and otherwise the default output device.
+func FindDevice(q Query) (*DeviceInfo, error) {
+	matches, err := FindDevices(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) > 0 {
+		return matches[0].Device, nil
+	}
+	if q.MinInputChannels > 0 && q.MinOutputChannels == 0 {
+		return DefaultInputDevice()
+	}
+	return DefaultOutputDevice()
+}
+
+func isDefault(d *DeviceInfo) bool {
+	return d.HostApi != nil && (d.HostApi.DefaultInputDevice == d || d.HostApi.DefaultOutputDevice == d)
+}
+
+func supportsSampleRate(d *DeviceInfo, q Query) bool {
+	params := func(channels int, latency float64) *C.PaStreamParameters {
+		if channels < 1 {
+			channels = 1
+		}
+		return &C.PaStreamParameters{
+			device:           C.int(d.Index),
+			channelCount:     C.int(channels),
+			sampleFormat:     C.paFloat32,
+			suggestedLatency: C.PaTime(latency),
+		}
+	}
+	var in, out *C.PaStreamParameters
+	if q.MinInputChannels > 0 || q.MinOutputChannels == 0 && d.MaxOutputChannels == 0 {
+		in = params(q.MinInputChannels, d.DefaultHighInputLatency.Seconds())
+	}
+	if q.MinOutputChannels > 0 || in == nil {
+		out = params(q.MinOutputChannels, d.DefaultHighOutputLatency.Seconds())
+	}
+	return C.Pa_IsFormatSupported(in, out, C.double(q.SampleRate)) == C.paNoError
+}
+
+// matchName returns a score from 0 to 1 of how well name matches the query:
+// 1 for equal names, 0.9 if name contains query, 0.8 if it does ignoring punctuation and spacing,
+// and up to 0.7 for approximate matches with at most one typo per three characters.
+// All comparisons ignore case.
+func matchName(name, query string) float64 {
+	name, query = strings.ToLower(name), strings.ToLower(query)
+	switch {
+	case name == query:
+		return 1
+	case strings.Contains(name, query):
+		return .9
+	}
+	name, query = normalizeName(name), normalizeName(query)
+	if query == "" {
+		return 0
+	}
+	if strings.Contains(name, query) {
+		return .8
+	}
+	q := []rune(query)
+	d := substringDistance([]rune(name), q)
+	if d > len(q)/3 {
+		return 0
+	}
+	return .7 * (1 - float64(d)/float64(len(q)))
+}
+
+// normalizeName removes all but letters and digits from s.
+func normalizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+// substringDistance returns the least edit (Levenshtein) distance between q and any substring of s.
+func substringDistance(s, q []rune) int {
+	// prev[i] is the distance between q[:i] and the best substring of s ending at the current position.
+	prev := make([]int, len(q)+1)
+	cur := make([]int, len(q)+1)
+	for i := range prev {
+		prev[i] = i
+	}
+	best := prev[len(q)]
+	for _, r := range s {
+		cur[0] = 0
+		for i := 1; i <= len(q); i++ {
+			cost := 1
+			if q[i-1] == r {
+				cost = 0
+			}
+			cur[i] = prev[i-1] + cost
+			if prev[i]+1 < cur[i] {
+				cur[i] = prev[i] + 1
+			}
+			if cur[i-1]+1 < cur[i] {
+				cur[i] = cur[i-1] + 1
+			}
+		}
+		if cur[len(q)] < best {
+			best = cur[len(q)]
+		}
+		prev, cur = cur, prev
+	}
+	return best
+}
@@ -0,0 +1,318 @@
+package portaudio
+
+/*
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"math"
+	"reflect"
+	"unsafe"
+)
+
+// nativeSampleTypes maps the kinds of Buffer sample types that PortAudio
+// does not support directly to the closest native sample type.
+var nativeSampleTypes = map[reflect.Kind]reflect.Type{
+	reflect.Float64: reflect.TypeOf(float32(0)),
+	reflect.Int64:   reflect.TypeOf(int32(0)),
+	reflect.Uint32:  reflect.TypeOf(int32(0)),
+	reflect.Uint16:  reflect.TypeOf(int16(0)),
+}
+
+// A converter translates between a Buffer of a non-native sample type
+// (the user Buffer) and a Buffer of the closest native sample type
+// (the native Buffer) that PortAudio reads from or writes to.
+//
+// In a callback stream the bindings own the user Buffer, which is resized
+// to the number of frames of each callback.  In a blocking stream the
+// bindings own the native Buffer, which is resized to match the user Buffer.
+type converter struct {
+	user, native     reflect.Value
+	store            reflect.Value // backs whichever Buffer is owned by the bindings
+	channels         int
+	nonInterleaved   bool
+	toUser, toNative func(dst, src unsafe.Pointer, n int)
+	clip, dither     bool
+	ditherSeed       uint32
+}
+
+// newConverter returns a converter for Buffers of type t, or nil if
+// t has a native sample type.  The user and native Buffers are allocated
+// but empty.
+func newConverter(t reflect.Type, channels int, flags StreamFlags) *converter {
+	nonInterleaved := t.Elem().Kind() == reflect.Slice
+	elem := t.Elem()
+	if nonInterleaved {
+		elem = elem.Elem()
+	}
+	nativeElem, ok := nativeSampleTypes[elem.Kind()]
+	if !ok {
+		return nil
+	}
+	nativeType := reflect.SliceOf(nativeElem)
+	if nonInterleaved {
+		nativeType = reflect.SliceOf(nativeType)
+	}
+	c := &converter{
+		user:           reflect.New(t).Elem(),
+		native:         reflect.New(nativeType).Elem(),
+		channels:       channels,
+		nonInterleaved: nonInterleaved,
+		clip:           flags&ClipOff == 0,
+		dither:         flags&DitherOff == 0,
+		ditherSeed:     22222,
+	}
+	if nonInterleaved {
+		c.user.Set(reflect.MakeSlice(t, channels, channels))
+		c.native.Set(reflect.MakeSlice(nativeType, channels, channels))
+	}
+	switch elem.Kind() {
+	case reflect.Float64:
+		c.toUser, c.toNative = float32ToFloat64, c.float64ToFloat32
+	case reflect.Int64:
+		c.toUser, c.toNative = int32ToInt64, c.int64ToInt32
+	case reflect.Uint32:
+		c.toUser, c.toNative = int32ToUint32, uint32ToInt32
+	case reflect.Uint16:
+		c.toUser, c.toNative = int16ToUint16, uint16ToInt16
+	}
+	return c
+}
+
+// header returns the header of the Buffer b, which must be addressable.
+func header(b reflect.Value) *reflect.SliceHeader {
+	return (*reflect.SliceHeader)(unsafe.Pointer(b.UnsafeAddr()))
+}
+
+// alloc resizes the Buffer b, which must be owned by the bindings, to the given number of frames.
+// Storage is only reallocated when it grows.
+func (c *converter) alloc(b reflect.Value, frames int) {
+	t := b.Type()
+	if c.nonInterleaved {
+		t = t.Elem()
+	}
+	n := frames * c.channels
+	if !c.store.IsValid() || c.store.Len() < n {
+		c.store = reflect.MakeSlice(t, n, n)
+	}
+	if !c.nonInterleaved {
+		b.Set(c.store.Slice(0, n))
+		return
+	}
+	for i := 0; i < c.channels; i++ {
+		b.Index(i).Set(c.store.Slice(i*frames, (i+1)*frames))
+	}
+}
+
+// userFrames returns the number of frames in the user Buffer of a blocking stream.
+func (c *converter) userFrames(p *C.PaStreamParameters) (int, error) {
+	_, frames, err := getBuffer(header(c.user), p)
+	return frames, err
+}
+
+// in converts the native Buffer into the user Buffer.
+func (c *converter) in() {
+	c.convert(c.user, c.native, c.toUser)
+}
+
+// out converts the user Buffer into the native Buffer.
+func (c *converter) out() {
+	c.convert(c.native, c.user, c.toNative)
+}
+
+func (c *converter) convert(dst, src reflect.Value, f func(dst, src unsafe.Pointer, n int)) {
+	if !c.nonInterleaved {
+		f(unsafe.Pointer(dst.Pointer()), unsafe.Pointer(src.Pointer()), src.Len())
+		return
+	}
+	for i := 0; i < c.channels; i++ {
+		d, s := dst.Index(i), src.Index(i)
+		f(unsafe.Pointer(d.Pointer()), unsafe.Pointer(s.Pointer()), s.Len())
+	}
+}
+
+// triangularDither returns triangular (TPDF) noise in the range (-1<<32, 1<<32),
+// using the same generator as PortAudio's own converters.
+func (c *converter) triangularDither() int64 {
+	c.ditherSeed = c.ditherSeed*196314165 + 907633515
+	r1 := int64(c.ditherSeed)
+	c.ditherSeed = c.ditherSeed*196314165 + 907633515
+	r2 := int64(c.ditherSeed)
+	return r1 - r2
+}
+
+func float32ToFloat64(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*float64)(dst), n), unsafe.Slice((*float32)(src), n)
+	for i, x := range s {
+		d[i] = float64(x)
+	}
+}
+
+func (c *converter) float64ToFloat32(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*float32)(dst), n), unsafe.Slice((*float64)(src), n)
+	for i, x := range s {
+		if c.clip {
+			x = math.Max(-1, math.Min(1, x))
+		}
+		d[i] = float32(x)
+	}
+}
+
+func int32ToInt64(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*int64)(dst), n), unsafe.Slice((*int32)(src), n)
+	for i, x := range s {
+		d[i] = int64(x) << 32
+	}
+}
+
+func (c *converter) int64ToInt32(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*int32)(dst), n), unsafe.Slice((*int64)(src), n)
+	for i, x := range s {
+		// Round to the nearest 32-bit value, or add dither of ±1 LSB.
+		offset := int64(1 << 31)
+		if c.dither {
+			offset += c.triangularDither()
+		}
+		y := x + offset
+		if c.clip {
+			if offset > 0 && y < x {
+				y = math.MaxInt64
+			} else if offset < 0 && y > x {
+				y = math.MinInt64
+			}
+		}
+		d[i] = int32(y >> 32)
+	}
+}
+
+func int32ToUint32(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*uint32)(dst), n), unsafe.Slice((*int32)(src), n)
+	for i, x := range s {
+		d[i] = uint32(x) ^ 1<<31
+	}
+}
+
+func uint32ToInt32(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*int32)(dst), n), unsafe.Slice((*uint32)(src), n)
+	for i, x := range s {
+		d[i] = int32(x ^ 1<<31)
+	}
+}
+
+func int16ToUint16(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*uint16)(dst), n), unsafe.Slice((*int16)(src), n)
+	for i, x := range s {
+		d[i] = uint16(x) ^ 1<<15
+	}
+}
+
+func uint16ToInt16(dst, src unsafe.Pointer, n int) {
+	d, s := unsafe.Slice((*int16)(dst), n), unsafe.Slice((*uint16)(src), n)
+	for i, x := range s {
+		d[i] = int16(x ^ 1<<15)
+	}
+}
+
+// float32Funcs returns functions that convert samples of type t (which may be any Buffer sample type)
+// to and from float32, or nil functions if t is float32.  Integers are scaled as by the convert package,
+// with rounding and clipping.
+func float32Funcs(t reflect.Type) (toFloat32 func(dst []float32, src unsafe.Pointer), fromFloat32 func(dst unsafe.Pointer, src []float32)) {
+	if t == reflect.TypeOf(Int24{}) {
+		return func(dst []float32, src unsafe.Pointer) {
+				Int24ToFloat32(dst, unsafe.Slice((*Int24)(src), len(dst)))
+			}, func(dst unsafe.Pointer, src []float32) {
+				Float32ToInt24(unsafe.Slice((*Int24)(dst), len(src)), src)
+			}
+	}
+	switch t.Kind() {
+	case reflect.Float64:
+		return func(dst []float32, src unsafe.Pointer) {
+				for i, x := range unsafe.Slice((*float64)(src), len(dst)) {
+					dst[i] = float32(x)
+				}
+			}, func(dst unsafe.Pointer, src []float32) {
+				d := unsafe.Slice((*float64)(dst), len(src))
+				for i, x := range src {
+					d[i] = float64(x)
+				}
+			}
+	case reflect.Int64:
+		return func(dst []float32, src unsafe.Pointer) {
+				for i, x := range unsafe.Slice((*int64)(src), len(dst)) {
+					dst[i] = float32(x>>32) * (1.0 / (1 << 31))
+				}
+			}, func(dst unsafe.Pointer, src []float32) {
+				d := unsafe.Slice((*int64)(dst), len(src))
+				for i, x := range src {
+					d[i] = int64(float32ToInt(x, 32)) << 32
+				}
+			}
+	case reflect.Int32:
+		return intToFloat32[int32](32, 0), float32ToInteger[int32](32, 0)
+	case reflect.Uint32:
+		return intToFloat32[uint32](32, 1<<31), float32ToInteger[uint32](32, 1<<31)
+	case reflect.Int16:
+		return intToFloat32[int16](16, 0), float32ToInteger[int16](16, 0)
+	case reflect.Uint16:
+		return intToFloat32[uint16](16, 1<<15), float32ToInteger[uint16](16, 1<<15)
+	case reflect.Int8:
+		return intToFloat32[int8](8, 0), float32ToInteger[int8](8, 0)
+	case reflect.Uint8:
+		return intToFloat32[uint8](8, 1<<7), float32ToInteger[uint8](8, 1<<7)
+	}
+	return nil, nil
+}
+
+type integerSample interface {
+	int32 | uint32 | int16 | uint16 | int8 | uint8
+}
+
+// intToFloat32 converts integers of the given number of bits, offset binary if offset is nonzero, to float32.
+func intToFloat32[T integerSample](bits uint, offset int64) func(dst []float32, src unsafe.Pointer) {
+	scale := 1 / float64(int64(1)<<(bits-1))
+	return func(dst []float32, src unsafe.Pointer) {
+		for i, x := range unsafe.Slice((*T)(src), len(dst)) {
+			dst[i] = float32(float64(int64(x)-offset) * scale)
+		}
+	}
+}
+
+// float32ToInteger is the inverse of intToFloat32.
+func float32ToInteger[T integerSample](bits uint, offset int64) func(dst unsafe.Pointer, src []float32) {
+	return func(dst unsafe.Pointer, src []float32) {
+		d := unsafe.Slice((*T)(dst), len(src))
+		for i, x := range src {
+			d[i] = T(float32ToInt(x, bits) + offset)
+		}
+	}
+}
+
+// float32ToInt scales f from [-1, 1] to a signed integer of the given number of bits, rounding and clipping.
+func float32ToInt(f float32, bits uint) int64 {
+	max := float64(int64(1)<<(bits-1) - 1)
+	x := math.Round(float64(f) * max)
+	if x != x {
+		return 0
+	}
+	return int64(math.Max(-max-1, math.Min(max, x)))
+}
+
+// sampleType returns the sample type of the PortAudio sample format f.
+func sampleType(f C.PaSampleFormat) reflect.Type {
+	switch f &^ C.paNonInterleaved {
+	case C.paFloat32:
+		return reflect.TypeOf(float32(0))
+	case C.paInt32:
+		return reflect.TypeOf(int32(0))
+	case C.paInt24:
+		return reflect.TypeOf(Int24{})
+	case C.paInt16:
+		return reflect.TypeOf(int16(0))
+	case C.paInt8:
+		return reflect.TypeOf(int8(0))
+	case C.paUInt8:
+		return reflect.TypeOf(uint8(0))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package portaudio
+
+import "math"
+
+// Int32 returns the value of i24 in the three most significant bytes of an int32,
+// the inverse of PutInt32.  The least significant byte is zero.
+func (i24 Int24) Int32() int32 {
+	if littleEndian {
+		return int32(i24[0])<<8 | int32(i24[1])<<16 | int32(int8(i24[2]))<<24
+	}
+	return int32(i24[2])<<8 | int32(i24[1])<<16 | int32(int8(i24[0]))<<24
+}
+
+// Float32 returns the value of i24 scaled to the range [-1, 1).
+//
+// Like PortAudio's converters, Float32 divides by 2^23 but SetFloat32 multiplies by 2^23-1,
+// so that 1 does not overflow.  Consequently i24.SetFloat32(i24.Float32()) moves values whose
+// magnitude exceeds 2^22 one step toward zero; use Int32 and PutInt32 to copy samples exactly.
+func (i24 Int24) Float32() float32 {
+	return float32(i24.Int32()>>8) * (1.0 / 0x800000)
+}
+
+// SetFloat32 sets i24 to f scaled from the range [-1, 1], clipping values outside that range.
+// It multiplies by 2^23-1, mirroring PortAudio, and so is not the exact inverse of Float32.
+func (i24 *Int24) SetFloat32(f float32) {
+	i24.PutInt32(float32ToInt32Bits24(f))
+}
+
+// float32ToInt32Bits24 scales f to 24 bits, in the three most significant bytes of an int32.
+func float32ToInt32Bits24(f float32) int32 {
+	if f > 1 {
+		f = 1
+	} else if f < -1 {
+		f = -1
+	}
+	return int32(math.Round(float64(f)*0x7fffff)) << 8
+}
+
+// Int24FromLittleEndian returns the Int24 stored in little-endian byte order in b[:3].
+func Int24FromLittleEndian(b []byte) (i24 Int24) {
+	_ = b[2] // bounds check hint to compiler
+	if littleEndian {
+		i24[0], i24[1], i24[2] = b[0], b[1], b[2]
+	} else {
+		i24[0], i24[1], i24[2] = b[2], b[1], b[0]
+	}
+	return i24
+}
+
+// Int24FromBigEndian returns the Int24 stored in big-endian byte order in b[:3].
+func Int24FromBigEndian(b []byte) (i24 Int24) {
+	_ = b[2] // bounds check hint to compiler
+	if littleEndian {
+		i24[0], i24[1], i24[2] = b[2], b[1], b[0]
+	} else {
+		i24[0], i24[1], i24[2] = b[0], b[1], b[2]
+	}
+	return i24
+}
+
+// PutLittleEndian stores i24 in little-endian byte order in b[:3].
+func (i24 Int24) PutLittleEndian(b []byte) {
+	_ = b[2] // bounds check hint to compiler
+	if littleEndian {
+		b[0], b[1], b[2] = i24[0], i24[1], i24[2]
+	} else {
+		b[0], b[1], b[2] = i24[2], i24[1], i24[0]
+	}
+}
+
+// PutBigEndian stores i24 in big-endian byte order in b[:3].
+func (i24 Int24) PutBigEndian(b []byte) {
+	_ = b[2] // bounds check hint to compiler
+	if littleEndian {
+		b[0], b[1], b[2] = i24[2], i24[1], i24[0]
+	} else {
+		b[0], b[1], b[2] = i24[0], i24[1], i24[2]
+	}
+}
+
+// The following bulk converters convert min(len(dst), len(src)) samples
+// and return that number.  They do not allocate, so they are safe
+// to call from a StreamCallback.
+
+// Int24ToInt32 converts samples as by Int24.Int32.
+func Int24ToInt32(dst []int32, src []Int24) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	dst, src = dst[:n], src[:n]
+	if littleEndian {
+		for i, s := range src {
+			dst[i] = int32(s[0])<<8 | int32(s[1])<<16 | int32(int8(s[2]))<<24
+		}
+	} else {
+		for i, s := range src {
+			dst[i] = int32(s[2])<<8 | int32(s[1])<<16 | int32(int8(s[0]))<<24
+		}
+	}
+	return n
+}
+
+// Int32ToInt24 converts samples as by Int24.PutInt32, truncating the least significant byte.
+func Int32ToInt24(dst []Int24, src []int32) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	dst, src = dst[:n], src[:n]
+	if littleEndian {
+		for i, s := range src {
+			dst[i] = Int24{byte(s >> 8), byte(s >> 16), byte(s >> 24)}
+		}
+	} else {
+		for i, s := range src {
+			dst[i] = Int24{byte(s >> 24), byte(s >> 16), byte(s >> 8)}
+		}
+	}
+	return n
+}
+
+// Int24ToFloat32 converts samples as by Int24.Float32.
+func Int24ToFloat32(dst []float32, src []Int24) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	for i, s := range src[:n] {
+		dst[i] = s.Float32()
+	}
+	return n
+}
+
+// Float32ToInt24 converts samples as by Int24.SetFloat32.
+func Float32ToInt24(dst []Int24, src []float32) int {
+	n := len(src)
+	if len(dst) < n {
+		n = len(dst)
+	}
+	for i, s := range src[:n] {
+		dst[i].PutInt32(float32ToInt32Bits24(s))
+	}
+	return n
+}
@@ -0,0 +1,122 @@
+package portaudio
+
+// The following functions convert between the interleaved ([]SampleType)
+// and non-interleaved ([][]SampleType) forms of a Buffer.  They never
+// allocate; the caller provides the destination, so they are safe to call
+// from a StreamCallback.  Each returns the number of frames processed,
+// which is limited by the shortest of its arguments.
+
+// Interleave copies the channels of src into the interleaved dst,
+// which has len(src) channels.
+func Interleave[T any](dst []T, src [][]T) int {
+	channels := len(src)
+	if channels == 0 {
+		return 0
+	}
+	frames := len(dst) / channels
+	for _, ch := range src {
+		if len(ch) < frames {
+			frames = len(ch)
+		}
+	}
+	for c, ch := range src {
+		for i, x := range ch[:frames] {
+			dst[i*channels+c] = x
+		}
+	}
+	return frames
+}
+
+// Deinterleave copies the interleaved src, which has len(dst) channels,
+// into the channels of dst.
+func Deinterleave[T any](dst [][]T, src []T) int {
+	channels := len(dst)
+	if channels == 0 {
+		return 0
+	}
+	frames := len(src) / channels
+	for _, ch := range dst {
+		if len(ch) < frames {
+			frames = len(ch)
+		}
+	}
+	for c, ch := range dst {
+		ch = ch[:frames]
+		for i := range ch {
+			ch[i] = src[i*channels+c]
+		}
+	}
+	return frames
+}
+
+// ExtractChannel copies the given channel of the interleaved src,
+// which has the given number of channels, into dst.
+func ExtractChannel[T any](dst, src []T, channels, channel int) int {
+	checkChannel(channels, channel)
+	frames := len(src) / channels
+	if len(dst) < frames {
+		frames = len(dst)
+	}
+	for i := range dst[:frames] {
+		dst[i] = src[i*channels+channel]
+	}
+	return frames
+}
+
+// InsertChannel copies src into the given channel of the interleaved dst,
+// which has the given number of channels.  The other channels are unchanged.
+func InsertChannel[T any](dst, src []T, channels, channel int) int {
+	checkChannel(channels, channel)
+	frames := len(dst) / channels
+	if len(src) < frames {
+		frames = len(src)
+	}
+	for i, x := range src[:frames] {
+		dst[i*channels+channel] = x
+	}
+	return frames
+}
+
+func checkChannel(channels, channel int) {
+	if channel < 0 || channel >= channels {
+		panic("portaudio: channel out of range")
+	}
+}
+
+// A StridedChannel is a view of one channel of an interleaved Buffer.
+// Changes to the view are reflected in the Buffer and vice versa.
+type StridedChannel[T any] struct {
+	buf               []T
+	channels, channel int
+}
+
+// NewStridedChannel returns a view of the given channel of the interleaved buf,
+// which has the given number of channels.
+func NewStridedChannel[T any](buf []T, channels, channel int) StridedChannel[T] {
+	checkChannel(channels, channel)
+	return StridedChannel[T]{buf, channels, channel}
+}
+
+// StridedChannels fills views with views of the channels of the interleaved buf,
+// which has len(views) channels, and returns views.
+func StridedChannels[T any](views []StridedChannel[T], buf []T) []StridedChannel[T] {
+	for c := range views {
+		views[c] = StridedChannel[T]{buf, len(views), c}
+	}
+	return views
+}
+
+// Len returns the number of frames in c.
+func (c StridedChannel[T]) Len() int {
+	return len(c.buf) / c.channels
+}
+
+// At returns the sample of frame i.
+func (c StridedChannel[T]) At(i int) T {
+	return c.buf[i*c.channels+c.channel]
+}
+
+// Set sets the sample of frame i to x.
+func (c StridedChannel[T]) Set(i int, x T) {
+	c.buf[i*c.channels+c.channel] = x
+}
@@ -0,0 +1,341 @@
+// Package pcm encodes and decodes the sample data of audio files to and from portaudio Buffers.
+package pcm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/convert"
+)
+
+// An Encoding describes how samples are stored in a file.
+type Encoding struct {
+	Bits      int  // 8, 16, 24, or 32 for integers; 32 or 64 for floats
+	Float     bool // IEEE floating point
+	Signed8   bool // 8-bit samples are signed, rather than offset binary
+	BigEndian bool
+
+	// Precision, if nonzero and less than Bits, is the number of significant bits of integer samples.
+	// Encode clears the others.
+	Precision int
+}
+
+// Valid reports whether e is a supported encoding.
+func (e Encoding) Valid() bool {
+	if e.Float {
+		return e.Bits == 32 || e.Bits == 64
+	}
+	return e.Bits == 8 || e.Bits == 16 || e.Bits == 24 || e.Bits == 32
+}
+
+// Size returns the number of bytes per sample.
+func (e Encoding) Size() int {
+	return e.Bits / 8
+}
+
+func (e Encoding) order() binary.ByteOrder {
+	if e.BigEndian {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// A Codec converts between encoded sample data and Buffers: interleaved ([]T) or non-interleaved ([][]T)
+// slices of float32, int32, portaudio.Int24, int16, int8, or uint8.  It reuses its scratch space,
+// so it should be used for only one stream at a time.
+type Codec struct {
+	Encoding
+	Channels int
+
+	// Converter quantizes narrowing conversions.  A nil Converter truncates.
+	Converter *convert.Converter
+
+	f32 []float32
+	i32 []int32
+	i24 []portaudio.Int24
+	i16 []int16
+	i8  []int8
+
+	// interleaved is scratch space of the Buffer's sample type, for non-interleaved Buffers.
+	interleaved interface{}
+}
+
+// FrameSize returns the number of bytes per frame.
+func (c *Codec) FrameSize() int {
+	return c.Channels * c.Size()
+}
+
+// BufferFrames returns the number of frames that buf holds.
+// It returns an error if buf is not a Buffer or if it is non-interleaved with the wrong number of channels.
+func (c *Codec) BufferFrames(buf portaudio.Buffer) (int, error) {
+	switch b := buf.(type) {
+	case []float32:
+		return len(b) / c.Channels, nil
+	case []int32:
+		return len(b) / c.Channels, nil
+	case []portaudio.Int24:
+		return len(b) / c.Channels, nil
+	case []int16:
+		return len(b) / c.Channels, nil
+	case []int8:
+		return len(b) / c.Channels, nil
+	case []uint8:
+		return len(b) / c.Channels, nil
+	case [][]float32:
+		return planarFrames(b, c.Channels)
+	case [][]int32:
+		return planarFrames(b, c.Channels)
+	case [][]portaudio.Int24:
+		return planarFrames(b, c.Channels)
+	case [][]int16:
+		return planarFrames(b, c.Channels)
+	case [][]int8:
+		return planarFrames(b, c.Channels)
+	case [][]uint8:
+		return planarFrames(b, c.Channels)
+	}
+	return 0, fmt.Errorf("unsupported buffer type %T", buf)
+}
+
+func planarFrames[T any](b [][]T, channels int) (int, error) {
+	if len(b) != channels {
+		return 0, fmt.Errorf("buffer has %d channels, not %d", len(b), channels)
+	}
+	frames := len(b[0])
+	for _, ch := range b {
+		if len(ch) < frames {
+			frames = len(ch)
+		}
+	}
+	return frames, nil
+}
+
+// Decode decodes the whole frames of src into the beginning of buf, which must hold them.
+func (c *Codec) Decode(buf portaudio.Buffer, src []byte) {
+	switch b := buf.(type) {
+	case []float32:
+		decode(c, b, nil, src)
+	case []int32:
+		decode(c, b, nil, src)
+	case []portaudio.Int24:
+		decode(c, b, nil, src)
+	case []int16:
+		decode(c, b, nil, src)
+	case []int8:
+		decode(c, b, nil, src)
+	case []uint8:
+		decode(c, b, nil, src)
+	case [][]float32:
+		decode(c, nil, b, src)
+	case [][]int32:
+		decode(c, nil, b, src)
+	case [][]portaudio.Int24:
+		decode(c, nil, b, src)
+	case [][]int16:
+		decode(c, nil, b, src)
+	case [][]int8:
+		decode(c, nil, b, src)
+	case [][]uint8:
+		decode(c, nil, b, src)
+	}
+}
+
+// Encode appends the first frames of buf, encoded, to dst.
+func (c *Codec) Encode(dst []byte, buf portaudio.Buffer, frames int) []byte {
+	switch b := buf.(type) {
+	case []float32:
+		return encode(c, dst, b, nil, frames)
+	case []int32:
+		return encode(c, dst, b, nil, frames)
+	case []portaudio.Int24:
+		return encode(c, dst, b, nil, frames)
+	case []int16:
+		return encode(c, dst, b, nil, frames)
+	case []int8:
+		return encode(c, dst, b, nil, frames)
+	case []uint8:
+		return encode(c, dst, b, nil, frames)
+	case [][]float32:
+		return encode(c, dst, nil, b, frames)
+	case [][]int32:
+		return encode(c, dst, nil, b, frames)
+	case [][]portaudio.Int24:
+		return encode(c, dst, nil, b, frames)
+	case [][]int16:
+		return encode(c, dst, nil, b, frames)
+	case [][]int8:
+		return encode(c, dst, nil, b, frames)
+	case [][]uint8:
+		return encode(c, dst, nil, b, frames)
+	}
+	return dst
+}
+
+// decode decodes src into either the interleaved or the non-interleaved buffer.
+func decode[T convert.Sample](c *Codec, interleaved []T, planar [][]T, src []byte) {
+	size := c.Size()
+	n := len(src) / c.FrameSize() * c.Channels
+	dst := interleaved
+	if planar != nil {
+		dst = scratch[T](c, n)
+	}
+	dst = dst[:n]
+	order := c.order()
+	switch {
+	case c.Float && c.Bits == 64:
+		s := grow(&c.f32, n)
+		for i := range s {
+			s[i] = float32(math.Float64frombits(order.Uint64(src[i*size:])))
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	case c.Float:
+		s := grow(&c.f32, n)
+		for i := range s {
+			s[i] = math.Float32frombits(order.Uint32(src[i*size:]))
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	case c.Bits == 32:
+		s := grow(&c.i32, n)
+		for i := range s {
+			s[i] = int32(order.Uint32(src[i*size:]))
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	case c.Bits == 24:
+		s := grow(&c.i24, n)
+		for i := range s {
+			if c.BigEndian {
+				s[i] = portaudio.Int24FromBigEndian(src[i*size:])
+			} else {
+				s[i] = portaudio.Int24FromLittleEndian(src[i*size:])
+			}
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	case c.Bits == 16:
+		s := grow(&c.i16, n)
+		for i := range s {
+			s[i] = int16(order.Uint16(src[i*size:]))
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	case c.Signed8:
+		s := grow(&c.i8, n)
+		for i := range s {
+			s[i] = int8(src[i])
+		}
+		convert.Interleaved(c.Converter, dst, s)
+	default:
+		convert.Interleaved(c.Converter, dst, src[:n])
+	}
+	if planar != nil {
+		portaudio.Deinterleave(planar, dst)
+	}
+}
+
+// encode appends the first frames of either the interleaved or the non-interleaved buffer to dst.
+func encode[T convert.Sample](c *Codec, dst []byte, interleaved []T, planar [][]T, frames int) []byte {
+	n := frames * c.Channels
+	src := interleaved
+	if planar != nil {
+		src = scratch[T](c, n)
+		portaudio.Interleave(src, planar)
+	}
+	src = src[:n]
+	start := len(dst)
+	dst = growBytes(dst, n*c.Size())
+	b := dst[start:]
+	size := c.Size()
+	order := c.order()
+	switch {
+	case c.Float && c.Bits == 64:
+		s := grow(&c.f32, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			order.PutUint64(b[i*size:], math.Float64bits(float64(x)))
+		}
+	case c.Float:
+		s := grow(&c.f32, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			order.PutUint32(b[i*size:], math.Float32bits(x))
+		}
+	case c.Bits == 32:
+		s := grow(&c.i32, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			order.PutUint32(b[i*size:], uint32(x))
+		}
+	case c.Bits == 24:
+		s := grow(&c.i24, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			if c.BigEndian {
+				x.PutBigEndian(b[i*size:])
+			} else {
+				x.PutLittleEndian(b[i*size:])
+			}
+		}
+	case c.Bits == 16:
+		s := grow(&c.i16, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			order.PutUint16(b[i*size:], uint16(x))
+		}
+	case c.Signed8:
+		s := grow(&c.i8, n)
+		convert.Interleaved(c.Converter, s, src)
+		for i, x := range s {
+			b[i] = byte(x)
+		}
+	default:
+		convert.Interleaved(c.Converter, b, src)
+	}
+	if p := c.Precision; p > 0 && p < c.Bits && !c.Float {
+		clearLowBits(b, size, c.Bits-p, c.BigEndian)
+	}
+	return dst
+}
+
+// clearLowBits clears the k least significant bits of each sample, of the given size, in b.
+func clearLowBits(b []byte, size, k int, bigEndian bool) {
+	for i := 0; i < len(b); i += size {
+		for j, k := 0, k; k > 0; j, k = j+1, k-8 {
+			mask := byte(0)
+			if k < 8 {
+				mask = 0xff << k
+			}
+			if bigEndian {
+				b[i+size-1-j] &= mask
+			} else {
+				b[i+j] &= mask
+			}
+		}
+	}
+}
+
+// scratch returns n samples of the Codec's scratch space of type T.
+func scratch[T any](c *Codec, n int) []T {
+	s, _ := c.interleaved.([]T)
+	if cap(s) < n {
+		s = make([]T, n)
+		c.interleaved = s
+	}
+	return s[:n]
+}
+
+func grow[T any](s *[]T, n int) []T {
+	if cap(*s) < n {
+		*s = make([]T, n)
+	}
+	return (*s)[:n]
+}
+
+// growBytes extends b by n bytes.
+func growBytes(b []byte, n int) []byte {
+	if cap(b)-len(b) < n {
+		c := make([]byte, len(b), 2*cap(b)+n)
+		copy(c, b)
+		b = c
+	}
+	return b[:len(b)+n]
+}
@@ -0,0 +1,61 @@
+package pcm
+
+import (
+	"io"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// A Reader decodes the sample data of a file, read from R, into Buffers.
+type Reader struct {
+	R     io.Reader
+	Codec Codec
+
+	// Remaining is the number of bytes of sample data left to read,
+	// or -1 if unknown, in which case the data ends at the end of R.
+	Remaining int64
+
+	buf []byte
+}
+
+// Read decodes up to frames frames into buf, which must hold them, and returns the number read.
+// At the end of the data, it returns 0 and io.EOF.  If R ends before Remaining bytes have been read,
+// Read returns io.ErrUnexpectedEOF.
+func (r *Reader) Read(buf portaudio.Buffer, frames int) (int, error) {
+	frameSize := r.Codec.FrameSize()
+	n := frames
+	if r.Remaining >= 0 && int64(n) > r.Remaining/int64(frameSize) {
+		n = int(r.Remaining / int64(frameSize))
+	}
+	if n == 0 {
+		if r.Remaining == 0 {
+			return 0, io.EOF
+		}
+		return 0, nil
+	}
+	if cap(r.buf) < n*frameSize {
+		r.buf = make([]byte, n*frameSize)
+	}
+	b := r.buf[:n*frameSize]
+	m, err := io.ReadFull(r.R, b)
+	n = m / frameSize
+	r.Codec.Decode(buf, b[:n*frameSize])
+	if r.Remaining >= 0 {
+		r.Remaining -= int64(m)
+	}
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		if r.Remaining > 0 {
+			return n, io.ErrUnexpectedEOF
+		}
+		// A streamed file ends wherever it ends, discarding any partial frame.
+		r.Remaining = 0
+		if n == 0 {
+			return 0, io.EOF
+		}
+	default:
+		return n, err
+	}
+	return n, nil
+}
@@ -0,0 +1,110 @@
+//go:build go1.23
+
+package portaudio
+
+import (
+	"context"
+	"iter"
+	"time"
+)
+
+// Blocks returns an iterator over blocks of input from a started blocking stream.
+// Each iteration reads into the input Buffer given to OpenStream and yields it.
+//
+// Iteration stops when the loop breaks, when ctx is done, or when Read fails
+// with an error other than InputOverflowed.  After iteration stops, BlocksErr
+// returns that error, or nil.
+func (s *Stream) Blocks(ctx context.Context) iter.Seq[Buffer] {
+	return func(yield func(Buffer) bool) {
+		for b := range s.BlocksWithInfo(ctx) {
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// BlocksWithInfo is like Blocks but also yields a BlockInfo for each block.
+//
+// Blocking streams have no timestamps, so the TimeInfo is estimated from the
+// stream's input latency and the time at which Read returned; only its
+// InputBufferAdcTime, CurrentTime, and InputFrame are set.  If input overflowed,
+// the InputOverflow flag is set.
+func (s *Stream) BlocksWithInfo(ctx context.Context) iter.Seq2[Buffer, BlockInfo] {
+	return func(yield func(Buffer, BlockInfo) bool) {
+		var err error
+		defer func() { s.blocksErr.Store(blocksResult{err}) }()
+		var sampleRate float64
+		var latency time.Duration
+		if i := s.Info(); i != nil {
+			sampleRate, latency = i.SampleRate, i.InputLatency
+		}
+		for ctx.Err() == nil {
+			var frames int
+			frames, err = s.read()
+			var info BlockInfo
+			if err == InputOverflowed {
+				err = nil
+				info.Flags = InputOverflow
+			} else if err != nil {
+				return
+			}
+			info.TimeInfo.InputFrame = s.InputPosition() - int64(frames)
+			info.TimeInfo.CurrentTime = s.Time()
+			info.TimeInfo.InputBufferAdcTime = info.TimeInfo.CurrentTime - latency
+			if sampleRate > 0 {
+				info.TimeInfo.InputBufferAdcTime -= time.Duration(float64(frames) / sampleRate * float64(time.Second))
+			}
+			if !yield(s.inBuf.Interface(), info) {
+				return
+			}
+		}
+	}
+}
+
+// BlocksErr returns the error that stopped the most recent iteration of Blocks
+// or BlocksWithInfo, or nil if it stopped because the loop broke or ctx was done.
+// It may be called concurrently with iteration.
+func (s *Stream) BlocksErr() error {
+	r, _ := s.blocksErr.Load().(blocksResult)
+	return r.err
+}
+
+// blocksResult wraps the error stored in Stream.blocksErr,
+// since an atomic.Value can't hold nil or values of different types.
+type blocksResult struct{ err error }
+
+// Blocks returns an iterator over blocks received from C.
+// Each block is released after it is yielded, so it must not be retained.
+//
+// Iteration stops when the loop breaks, when ctx is done, or when the stream is closed.
+func (c *CaptureChan[T]) Blocks(ctx context.Context) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		for b := range c.BlocksWithInfo(ctx) {
+			if !yield(b) {
+				return
+			}
+		}
+	}
+}
+
+// BlocksWithInfo is like Blocks but also yields the BlockInfo of each block.
+func (c *CaptureChan[T]) BlocksWithInfo(ctx context.Context) iter.Seq2[[]T, BlockInfo] {
+	return func(yield func([]T, BlockInfo) bool) {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case b, ok := <-c.C:
+				if !ok {
+					return
+				}
+				more := yield(b, c.Info(b))
+				c.Release(b)
+				if !more {
+					return
+				}
+			}
+		}
+	}
+}
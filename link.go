@@ -0,0 +1,219 @@
+package portaudio
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// A link carries interleaved float32 frames from one stream callback to another,
+// whose device may be driven by an independent clock.
+//
+// Frames are written with the stream time of their first frame and read
+// for a stream time, a fixed delay later.  The reader adaptively resamples so that the
+// frames it reads were written for exactly that time, compensating for clock drift.
+// Large misalignments (at startup, or after an underflow or overflow) are corrected
+// immediately by skipping frames or inserting silence.
+//
+// One callback may write while another reads.
+type link struct {
+	// Accessed atomically, so they must stay 64-bit aligned.
+	headFrames, headTime  int64 // total frames written, and the time (in ns) just after the last
+	overflows, underflows uint64
+	seq                   uint32 // odd while the head is being updated
+
+	ring       *RingBuffer[float32]
+	channels   int
+	sampleRate float64
+	delay      time.Duration
+
+	// Reader state.
+	consumed int64     // frames read from ring
+	pending  []float32 // frames read from ring and not yet passed through the resampler
+	pos      float64   // position of the resampler in pending, in frames
+	step     float64   // input frames per output frame
+	integral float64
+	silence  int // frames of silence to emit before resuming
+}
+
+const (
+	linkMaxAdjust = .01 // maximum deviation of step from 1
+	linkKP        = 1e-5
+	linkKI        = 1e-8
+)
+
+func newLink(channels int, sampleRate float64, delay time.Duration) *link {
+	frames := 4 * int(DurationToFrames(delay, sampleRate))
+	if frames < 1024 {
+		frames = 1024
+	}
+	return &link{
+		ring:       NewRingBuffer[float32](frames * channels),
+		channels:   channels,
+		sampleRate: sampleRate,
+		delay:      delay,
+		pending:    make([]float32, 0, frames*channels),
+		step:       1,
+	}
+}
+
+// write writes the frames in buf, the first of which belongs at time t.
+func (l *link) write(buf []float32, t time.Duration) {
+	frames := len(buf) / l.channels
+	n := l.ringWrite(buf[:frames*l.channels]) / l.channels
+	if n < frames {
+		atomic.AddUint64(&l.overflows, uint64(frames-n))
+	}
+	atomic.AddUint32(&l.seq, 1)
+	atomic.StoreInt64(&l.headFrames, atomic.LoadInt64(&l.headFrames)+int64(n))
+	atomic.StoreInt64(&l.headTime, int64(t+FramesToDuration(int64(n), l.sampleRate)))
+	atomic.AddUint32(&l.seq, 1)
+}
+
+// head returns the number of frames written and the time just after the last.
+func (l *link) head() (int64, time.Duration) {
+	for {
+		seq := atomic.LoadUint32(&l.seq)
+		if seq&1 == 1 {
+			continue
+		}
+		frames, t := atomic.LoadInt64(&l.headFrames), atomic.LoadInt64(&l.headTime)
+		if atomic.LoadUint32(&l.seq) == seq {
+			return frames, time.Duration(t)
+		}
+	}
+}
+
+// read fills buf with the frames written for time t - delay and onward.
+func (l *link) read(buf []float32, t time.Duration) {
+	ch := l.channels
+	frames := len(buf) / ch
+	buf = buf[:frames*ch]
+	headFrames, headTime := l.head()
+	if headFrames == 0 {
+		zero(buf)
+		return
+	}
+
+	// How far ahead of the wanted time is the read position?
+	readFrame := float64(l.consumed-int64(len(l.pending)/ch)) + l.pos
+	readTime := headTime - FramesToDuration(headFrames, l.sampleRate) + time.Duration(readFrame/l.sampleRate*float64(time.Second))
+	e := (readTime - (t - l.delay)).Seconds() * l.sampleRate
+	if math.Abs(e) > float64(frames)+l.sampleRate*.01 {
+		// Realign immediately.
+		if e < 0 {
+			l.skip(int(-e))
+		} else {
+			l.silence = int(e)
+		}
+		e = 0
+	}
+	l.integral = clamp(l.integral+linkKI*e, linkMaxAdjust)
+	l.step = 1 - clamp(linkKP*e+l.integral, linkMaxAdjust)
+
+	if l.silence > 0 {
+		n := l.silence
+		if n > frames {
+			n = frames
+		}
+		zero(buf[:n*ch])
+		buf = buf[n*ch:]
+		frames -= n
+		l.silence -= n
+	}
+	l.resample(buf, frames)
+}
+
+// Overflows returns the number of frames dropped because the link was full.
+func (l *link) Overflows() uint64 {
+	return atomic.LoadUint64(&l.overflows)
+}
+
+// Underflows returns the number of frames that were missing when needed.
+func (l *link) Underflows() uint64 {
+	return atomic.LoadUint64(&l.underflows)
+}
+
+// skip discards n frames.
+func (l *link) skip(n int) {
+	ch := l.channels
+	l.pos = 0
+	if p := len(l.pending) / ch; n <= p {
+		l.pending = l.pending[:copy(l.pending, l.pending[n*ch:])]
+		return
+	}
+	n -= len(l.pending) / ch
+	l.pending = l.pending[:0]
+	for n > 0 {
+		a, b := l.ring.ReadRegions(n * ch)
+		k := (len(a) + len(b)) / ch
+		if k == 0 {
+			return
+		}
+		l.ring.CommitRead(k * ch)
+		l.consumed += int64(k)
+		n -= k
+	}
+}
+
+// resample fills buf with frames by linear interpolation of pending frames at intervals of step.
+func (l *link) resample(buf []float32, frames int) {
+	ch := l.channels
+	need := (int(l.pos+float64(frames)*l.step) + 2) * ch
+	if have := len(l.pending); have < need {
+		if cap(l.pending) < need {
+			p := make([]float32, have, 2*need)
+			copy(p, l.pending)
+			l.pending = p
+		}
+		l.pending = l.pending[:need]
+		n := l.ringRead(l.pending[have:need])
+		l.consumed += int64(n / ch)
+		if have+n < need {
+			atomic.AddUint64(&l.underflows, uint64((need-have-n)/ch))
+			l.pending = l.pending[:have+n]
+			zero(buf)
+			return
+		}
+	}
+	for k := 0; k < frames; k++ {
+		i := int(l.pos)
+		t := float32(l.pos - float64(i))
+		for c := 0; c < ch; c++ {
+			x0, x1 := l.pending[i*ch+c], l.pending[(i+1)*ch+c]
+			buf[k*ch+c] = x0 + t*(x1-x0)
+		}
+		l.pos += l.step
+	}
+	consumed := int(l.pos)
+	l.pos -= float64(consumed)
+	l.pending = l.pending[:copy(l.pending, l.pending[consumed*ch:])]
+}
+
+// ringWrite writes as many whole frames of p to the ring as fit and returns the number of samples written.
+// The ring's capacity need not be a multiple of the number of channels, so a plain Write could split a frame.
+func (l *link) ringWrite(p []float32) int {
+	a, b := l.ring.WriteRegions(len(p))
+	n := len(a) + len(b)
+	n -= n % l.channels
+	k := copy(a, p[:n])
+	copy(b, p[k:n])
+	l.ring.CommitWrite(n)
+	return n
+}
+
+// ringRead reads as many whole frames from the ring into p as are available and fit,
+// and returns the number of samples read.
+func (l *link) ringRead(p []float32) int {
+	a, b := l.ring.ReadRegions(len(p))
+	n := len(a) + len(b)
+	n -= n % l.channels
+	k := copy(p[:n], a)
+	copy(p[k:n], b)
+	l.ring.CommitRead(n)
+	return n
+}
+
+func clamp(x, max float64) float64 {
+	return math.Max(-max, math.Min(max, x))
+}
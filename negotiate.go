@@ -0,0 +1,193 @@
+package portaudio
+
+/*
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// A Negotiation reports the parameters with which OpenNegotiatedStream opened a stream.
+type Negotiation struct {
+	// Params are the parameters with which PortAudio opened the stream.  Their channel counts,
+	// sample rate, and frames per buffer may differ from those requested.
+	Params StreamParameters
+
+	// InputSampleType and OutputSampleType are the sample types exchanged with PortAudio,
+	// e.g., float32, or nil for an output- or input-only stream.
+	InputSampleType, OutputSampleType reflect.Type
+
+	// Converted, Remapped, and Resampled report whether the bindings convert samples
+	// between the callback's Buffers and PortAudio's, remap channels, or resample.
+	Converted, Remapped, Resampled bool
+}
+
+// commonSampleRates are tried, nearest first, when the devices' default sample rates are not supported.
+var commonSampleRates = []float64{8000, 11025, 16000, 22050, 32000, 44100, 48000, 88200, 96000, 176400, 192000}
+
+// OpenNegotiatedStream opens a stream with a StreamCallback like OpenStream but, if the devices
+// do not support p, it falls back to parameters that they do support, and adapts the buffers
+// so that the callback still receives the Buffer types, channel counts, and sample rate of p.
+//
+// The fallbacks are tried in this order:  sample rates (p.SampleRate, then the devices' default
+// sample rates, then common rates nearest p.SampleRate); for each, channel counts (p's, then each
+// device's maximum if lower, then stereo and mono); and for each, native sample formats (the callback's,
+// then float32, int32, Int24, and int16).  That is, converting is preferred to remapping, and
+// remapping to resampling.
+//
+// When remapping, channels are copied by index, except that a mono source is copied to every channel
+// and a mono destination receives the average of all channels; extra channels are silent.
+// When resampling, the quality is p.Resample (Medium if zero) and the callback is called with
+// varying numbers of frames, as described for OpenStream.
+func OpenNegotiatedStream(p StreamParameters, callback StreamCallback) (*Stream, *Negotiation, error) {
+	if initialized <= 0 {
+		return nil, nil, NotInitialized
+	}
+	if reflect.ValueOf(callback).Kind() != reflect.Func {
+		return nil, nil, fmt.Errorf("OpenNegotiatedStream requires a StreamCallback")
+	}
+
+	s := newStream()
+	if err := s.init(p, callback); err != nil {
+		delStream(s)
+		return nil, nil, err
+	}
+	sampleRate, framesPerBuffer := p.SampleRate, p.FramesPerBuffer
+	if err := newError(C.Pa_IsFormatSupported(s.inParams, s.outParams, C.double(sampleRate))); err != nil {
+		inParams, outParams, rate, ok := s.negotiate(p)
+		if !ok {
+			delStream(s)
+			return nil, nil, err
+		}
+		sampleRate = rate
+		framesPerBuffer = s.initAdapter(p, inParams, outParams, sampleRate)
+	}
+	if err := s.open(p, sampleRate, framesPerBuffer); err != nil {
+		delStream(s)
+		return nil, nil, err
+	}
+
+	n := &Negotiation{Params: p, Resampled: sampleRate != p.SampleRate}
+	n.Params.SampleRate = sampleRate
+	n.Params.FramesPerBuffer = framesPerBuffer
+	in, out := s.callbackBuffers()
+	if s.inParams != nil {
+		n.Params.Input.Channels = int(s.inParams.channelCount)
+		n.InputSampleType = sampleType(s.inParams.sampleFormat)
+		n.Remapped = n.Remapped || n.Params.Input.Channels != p.Input.Channels
+		n.Converted = n.Converted || bufferSampleType(in.Type()) != n.InputSampleType
+	}
+	if s.outParams != nil {
+		n.Params.Output.Channels = int(s.outParams.channelCount)
+		n.OutputSampleType = sampleType(s.outParams.sampleFormat)
+		n.Remapped = n.Remapped || n.Params.Output.Channels != p.Output.Channels
+		n.Converted = n.Converted || bufferSampleType(out.Type()) != n.OutputSampleType
+	}
+	return s, n, nil
+}
+
+// negotiate returns the most preferred native parameters and sample rate supported by the devices of p.
+func (s *Stream) negotiate(p StreamParameters) (inParams, outParams *C.PaStreamParameters, sampleRate float64, ok bool) {
+	var ins, outs [][]*C.PaStreamParameters
+	if s.inParams != nil {
+		ins = paramCandidates(s.inParams, p.Input.Device.MaxInputChannels)
+	} else {
+		ins = [][]*C.PaStreamParameters{{nil}}
+	}
+	if s.outParams != nil {
+		outs = paramCandidates(s.outParams, p.Output.Device.MaxOutputChannels)
+	} else {
+		outs = [][]*C.PaStreamParameters{{nil}}
+	}
+	for _, rate := range rateCandidates(p) {
+		// Try every sample format for a pair of channel counts before remapping further.
+		for _, inFormats := range ins {
+			for _, outFormats := range outs {
+				for _, in := range inFormats {
+					for _, out := range outFormats {
+						if C.Pa_IsFormatSupported(in, out, C.double(rate)) == C.paNoError {
+							return in, out, rate, true
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil, nil, 0, false
+}
+
+// paramCandidates returns interleaved variants of the stream parameters p, in order of preference,
+// grouped by channel count.
+func paramCandidates(p *C.PaStreamParameters, maxChannels int) [][]*C.PaStreamParameters {
+	requested := int(p.channelCount)
+	var channels []int
+	for _, c := range []int{requested, maxChannels, 2, 1} {
+		if c > 0 && (maxChannels <= 0 || c <= maxChannels) && !contains(channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	var formats []C.PaSampleFormat
+	for _, f := range []C.PaSampleFormat{p.sampleFormat &^ C.paNonInterleaved, C.paFloat32, C.paInt32, C.paInt24, C.paInt16} {
+		if !contains(formats, f) {
+			formats = append(formats, f)
+		}
+	}
+	var params [][]*C.PaStreamParameters
+	for _, c := range channels {
+		var group []*C.PaStreamParameters
+		for _, f := range formats {
+			q := *p
+			q.channelCount = C.int(c)
+			q.sampleFormat = f
+			group = append(group, &q)
+		}
+		params = append(params, group)
+	}
+	return params
+}
+
+// rateCandidates returns the sample rates to try for p, in order of preference.
+func rateCandidates(p StreamParameters) []float64 {
+	rates := []float64{p.SampleRate}
+	for _, d := range []*DeviceInfo{p.Output.Device, p.Input.Device} {
+		if d != nil && d.DefaultSampleRate > 0 && !contains(rates, d.DefaultSampleRate) {
+			rates = append(rates, d.DefaultSampleRate)
+		}
+	}
+	common := append([]float64(nil), commonSampleRates...)
+	distance := func(r float64) float64 { return math.Abs(math.Log(r / p.SampleRate)) }
+	sort.SliceStable(common, func(i, j int) bool {
+		// Prefer higher rates on ties, to avoid losing bandwidth.
+		di, dj := distance(common[i]), distance(common[j])
+		return di < dj || di == dj && common[i] > common[j]
+	})
+	for _, r := range common {
+		if !contains(rates, r) {
+			rates = append(rates, r)
+		}
+	}
+	return rates
+}
+
+// bufferSampleType returns the sample type of the Buffer type t.
+func bufferSampleType(t reflect.Type) reflect.Type {
+	t = t.Elem()
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t
+}
+
+func contains[T comparable](s []T, x T) bool {
+	for _, y := range s {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
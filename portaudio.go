@@ -20,12 +20,16 @@ import "C"
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
+
+	"github.com/gordonklaus/portaudio/resample"
 )
 
 // Version returns the release number of PortAudio.
@@ -219,6 +223,10 @@ type DeviceInfo struct {
 	DefaultHighOutputLatency time.Duration
 	DefaultSampleRate        float64
 	HostApi                  *HostApiInfo
+
+	// ID identifies the device across restarts and changes to the device list,
+	// so it may be saved in configuration files.  See DeviceByID.
+	ID string
 }
 
 // HostApis returns all information available for HostApis.
@@ -364,6 +372,7 @@ func hostsAndDevices() ([]*HostApiInfo, []*DeviceInfo, error) {
 		for i := range devices {
 			devices[i].HostApi = hostApis[hosti[i]]
 		}
+		assignDeviceIDs(devices)
 		cached = true
 	}
 	return hostApis, devices, nil
@@ -387,6 +396,10 @@ type StreamParameters struct {
 	SampleRate      float64
 	FramesPerBuffer int
 	Flags           StreamFlags
+
+	// Resample, if nonzero, allows OpenStream to resample a stream whose
+	// devices do not support SampleRate, with the given quality.  See OpenStream.
+	Resample resample.Quality
 }
 
 // StreamDeviceParameters specifies parameters for
@@ -520,15 +533,24 @@ func init() {
 //
 // Portable applications should assume that a Device may be simultaneously used by at most one Stream.
 type Stream struct {
+	// Frames read and written; accessed atomically, so they must stay 64-bit aligned.
+	inFrames, outFrames int64
+
 	id                  uintptr
 	paStream            unsafe.Pointer
 	inParams, outParams *C.PaStreamParameters
 	in, out             *reflect.SliceHeader
+	inConv, outConv     *converter
+	adapter             *streamAdapter
+	inBuf               reflect.Value // the input Buffer of a blocking stream
 	timeInfo            StreamCallbackTimeInfo
 	flags               StreamCallbackFlags
 	args                []reflect.Value
 	callback            reflect.Value
 	closed              bool
+	onClose             []func()
+
+	blocksErr atomic.Value // blocksResult of the last iteration of Blocks
 }
 
 /*
@@ -580,6 +602,13 @@ Buffer exists for documentation purposes only.
 A Buffer is of the form [][]SampleType or []SampleType
 where SampleType is float32, int32, Int24, int16, int8, or uint8.
 
+SampleType may also be float64, int64, uint32, or uint16, which PortAudio
+does not support natively.  The stream is then opened with the closest native
+format (float32, int32, int32, or int16, respectively) and samples are converted
+by the bindings.  Unsigned types are offset binary, like uint8.  On output,
+float64 samples are clipped to [-1, 1] and int64 samples are dithered when
+reduced to 32 bits; set the ClipOff or DitherOff StreamFlags to disable these.
+
 In the first form, channels are non-interleaved:
 len(buf) == numChannels and len(buf[i]) == framesPerBuffer
 
@@ -592,6 +621,11 @@ type Buffer interface{}
 // buffers passed to the stream callback.
 type StreamCallbackTimeInfo struct {
 	InputBufferAdcTime, CurrentTime, OutputBufferDacTime time.Duration
+
+	// InputFrame and OutputFrame are the positions of the first frames of the
+	// input and output buffers, counting frames since the stream was opened.
+	// See FrameTime and TimeFrame for sample-accurate conversions to stream time.
+	InputFrame, OutputFrame int64
 }
 
 // StreamCallbackFlags are flag bit constants for the statusFlags to StreamCallback.
@@ -637,6 +671,12 @@ const (
 // for a blocking stream, two Buffers or pointers to Buffers.
 //
 // For an input- or output-only stream, one of the Buffer args may be omitted.
+//
+// If p.Resample is nonzero, the args are a StreamCallback, and the devices do not support
+// p.SampleRate, then the stream is opened at the default sample rate of the output device
+// (or input device, for an input-only stream) and resampled.  The callback is then called with varying numbers of frames, about
+// p.FramesPerBuffer on average (see BlockAdapter), and InputFrame and OutputFrame
+// count frames at p.SampleRate.  Info reports the devices' sample rate.
 func OpenStream(p StreamParameters, args ...interface{}) (*Stream, error) {
 	if initialized <= 0 {
 		return nil, NotInitialized
@@ -648,16 +688,21 @@ func OpenStream(p StreamParameters, args ...interface{}) (*Stream, error) {
 		delStream(s)
 		return nil, err
 	}
+	sampleRate, framesPerBuffer := s.initResampler(p)
+	if err := s.open(p, sampleRate, framesPerBuffer); err != nil {
+		delStream(s)
+		return nil, err
+	}
+	return s, nil
+}
+
+// open opens the initialized stream s with the given sample rate and frames per buffer.
+func (s *Stream) open(p StreamParameters, sampleRate float64, framesPerBuffer int) error {
 	cb := C.paStreamCallback
 	if !s.callback.IsValid() {
 		cb = nil
 	}
-	paErr := C.Pa_OpenStream(&s.paStream, s.inParams, s.outParams, C.double(p.SampleRate), C.ulong(p.FramesPerBuffer), C.PaStreamFlags(p.Flags), cb, unsafe.Pointer(s.id))
-	if paErr != C.paNoError {
-		delStream(s)
-		return nil, newError(paErr)
-	}
-	return s, nil
+	return newError(C.Pa_OpenStream(&s.paStream, s.inParams, s.outParams, C.double(sampleRate), C.ulong(framesPerBuffer), C.PaStreamFlags(p.Flags), cb, unsafe.Pointer(s.id)))
 }
 
 // OpenDefaultStream is a simplified version of OpenStream that
@@ -717,36 +762,40 @@ func (s *Stream) initCallback(p StreamParameters, fun reflect.Value) error {
 	args := make([]reflect.Value, nArgs)
 	i := 0
 	bothBufs := nArgs > 1 && t.In(1).Kind() == reflect.Slice
-	bufArg := func(p StreamDeviceParameters) (*C.PaStreamParameters, *reflect.SliceHeader, error) {
-		if p.Device != nil || bothBufs {
+	bufArg := func(dp StreamDeviceParameters) (*C.PaStreamParameters, *reflect.SliceHeader, *converter, error) {
+		if dp.Device != nil || bothBufs {
 			if i >= nArgs {
-				return nil, nil, fmt.Errorf("too few Buffer parameters in StreamCallback")
+				return nil, nil, nil, fmt.Errorf("too few Buffer parameters in StreamCallback")
 			}
 			t := t.In(i)
 			sampleFmt := sampleFormat(t)
 			if sampleFmt == 0 {
-				return nil, nil, fmt.Errorf("expected Buffer type in StreamCallback, got %v", t)
+				return nil, nil, nil, fmt.Errorf("expected Buffer type in StreamCallback, got %v", t)
 			}
 			buf := reflect.New(t)
 			args[i] = buf.Elem()
 			i++
-			if p.Device != nil {
-				pap := paStreamParameters(p, sampleFmt)
+			if dp.Device != nil {
+				pap := paStreamParameters(dp, sampleFmt)
+				if c := newConverter(t, dp.Channels, p.Flags); c != nil {
+					args[i-1] = c.user
+					return pap, header(c.native), c, nil
+				}
 				if pap.sampleFormat&C.paNonInterleaved != 0 {
 					n := int(pap.channelCount)
 					buf.Elem().Set(reflect.MakeSlice(t, n, n))
 				}
-				return pap, (*reflect.SliceHeader)(unsafe.Pointer(buf.Pointer())), nil
+				return pap, (*reflect.SliceHeader)(unsafe.Pointer(buf.Pointer())), nil, nil
 			}
 		}
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	var err error
-	s.inParams, s.in, err = bufArg(p.Input)
+	s.inParams, s.in, s.inConv, err = bufArg(p.Input)
 	if err != nil {
 		return err
 	}
-	s.outParams, s.out, err = bufArg(p.Output)
+	s.outParams, s.out, s.outConv, err = bufArg(p.Output)
 	if err != nil {
 		return err
 	}
@@ -779,10 +828,11 @@ func (s *Stream) initCallback(p StreamParameters, fun reflect.Value) error {
 
 func (s *Stream) initBuffers(p StreamParameters, args ...interface{}) error {
 	bothBufs := len(args) == 2
-	bufArg := func(p StreamDeviceParameters) (*C.PaStreamParameters, *reflect.SliceHeader, error) {
-		if p.Device != nil || bothBufs {
+	var buf reflect.Value // the Buffer most recently taken from args
+	bufArg := func(dp StreamDeviceParameters) (*C.PaStreamParameters, *reflect.SliceHeader, *converter, error) {
+		if dp.Device != nil || bothBufs {
 			if len(args) == 0 {
-				return nil, nil, fmt.Errorf("too few Buffer args")
+				return nil, nil, nil, fmt.Errorf("too few Buffer args")
 			}
 			arg := reflect.ValueOf(args[0])
 			args = args[1:]
@@ -796,29 +846,99 @@ func (s *Stream) initBuffers(p StreamParameters, args ...interface{}) error {
 			}
 			sampleFmt := sampleFormat(t)
 			if sampleFmt == 0 {
-				return nil, nil, fmt.Errorf("invalid Buffer type %v", t)
+				return nil, nil, nil, fmt.Errorf("invalid Buffer type %v", t)
 			}
 			if arg.IsNil() {
-				return nil, nil, fmt.Errorf("nil Buffer pointer")
+				return nil, nil, nil, fmt.Errorf("nil Buffer pointer")
 			}
-			if p.Device != nil {
-				return paStreamParameters(p, sampleFmt), (*reflect.SliceHeader)(unsafe.Pointer(arg.Pointer())), nil
+			buf = arg.Elem()
+			if dp.Device != nil {
+				pap := paStreamParameters(dp, sampleFmt)
+				if c := newConverter(t, dp.Channels, p.Flags); c != nil {
+					c.user = arg.Elem()
+					return pap, header(c.native), c, nil
+				}
+				return pap, (*reflect.SliceHeader)(unsafe.Pointer(arg.Pointer())), nil, nil
 			}
 		}
-		return nil, nil, nil
+		return nil, nil, nil, nil
 	}
 	var err error
-	s.inParams, s.in, err = bufArg(p.Input)
+	s.inParams, s.in, s.inConv, err = bufArg(p.Input)
 	if err != nil {
 		return err
 	}
-	s.outParams, s.out, err = bufArg(p.Output)
+	s.inBuf = buf
+	s.outParams, s.out, s.outConv, err = bufArg(p.Output)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
+// initResampler sets up resampling for s if p allows and requires it (see OpenStream).
+// It returns the sample rate and frames per buffer with which to open s.
+func (s *Stream) initResampler(p StreamParameters) (float64, int) {
+	if p.Resample == 0 || !s.callback.IsValid() ||
+		C.Pa_IsFormatSupported(s.inParams, s.outParams, C.double(p.SampleRate)) != C.paInvalidSampleRate {
+		return p.SampleRate, p.FramesPerBuffer
+	}
+	dev := p.Output.Device
+	if dev == nil {
+		dev = p.Input.Device
+	}
+	nativeRate := dev.DefaultSampleRate
+	inParams, outParams := interleavedFloat32(s.inParams), interleavedFloat32(s.outParams)
+	if nativeRate == p.SampleRate || C.Pa_IsFormatSupported(inParams, outParams, C.double(nativeRate)) != C.paNoError {
+		return p.SampleRate, p.FramesPerBuffer
+	}
+	return nativeRate, s.initAdapter(p, inParams, outParams, nativeRate)
+}
+
+// initAdapter arranges for s, whose callback has been initialized for p, to be opened with
+// the given native parameters and sample rate, adapting its buffers for the callback.
+// It returns the frames per buffer with which to open s.
+func (s *Stream) initAdapter(p StreamParameters, inParams, outParams *C.PaStreamParameters, sampleRate float64) int {
+	a := &streamAdapter{}
+	ratio := p.SampleRate / sampleRate
+	in, out := s.callbackBuffers()
+	if s.inParams != nil {
+		a.in = newAdapterPath(in, int(s.inParams.channelCount), sampleType(inParams.sampleFormat), int(inParams.channelCount), ratio, p.Resample)
+	}
+	if s.outParams != nil {
+		a.out = newAdapterPath(out, int(s.outParams.channelCount), sampleType(outParams.sampleFormat), int(outParams.channelCount), 1/ratio, p.Resample)
+	}
+	s.adapter = a
+	s.inConv, s.outConv = nil, nil
+	s.inParams, s.outParams = inParams, outParams
+
+	framesPerBuffer := p.FramesPerBuffer
+	if framesPerBuffer != FramesPerBufferUnspecified && ratio != 1 {
+		framesPerBuffer = int(math.Max(1, math.Round(float64(framesPerBuffer)/ratio)))
+	}
+	return framesPerBuffer
+}
+
+// callbackBuffers returns the input and output Buffers passed to the callback of s.
+func (s *Stream) callbackBuffers() (in, out reflect.Value) {
+	bufs := 0
+	for _, arg := range s.args {
+		if arg.Kind() == reflect.Slice {
+			bufs++
+		}
+	}
+	return s.args[0], s.args[bufs-1]
+}
+
+func interleavedFloat32(p *C.PaStreamParameters) *C.PaStreamParameters {
+	if p == nil {
+		return nil
+	}
+	q := *p
+	q.sampleFormat = C.paFloat32
+	return &q
+}
+
 func sampleFormat(b reflect.Type) (f C.PaSampleFormat) {
 	if b.Kind() != reflect.Slice {
 		return 0
@@ -829,9 +949,9 @@ func sampleFormat(b reflect.Type) (f C.PaSampleFormat) {
 		b = b.Elem()
 	}
 	switch b.Kind() {
-	case reflect.Float32:
+	case reflect.Float32, reflect.Float64:
 		f |= C.paFloat32
-	case reflect.Int32:
+	case reflect.Int32, reflect.Int64, reflect.Uint32:
 		f |= C.paInt32
 	default:
 		if b == reflect.TypeOf(Int24{}) {
@@ -839,7 +959,7 @@ func sampleFormat(b reflect.Type) (f C.PaSampleFormat) {
 		} else {
 			return 0
 		}
-	case reflect.Int16:
+	case reflect.Int16, reflect.Uint16:
 		f |= C.paInt16
 	case reflect.Int8:
 		f |= C.paInt8
@@ -864,6 +984,9 @@ func (s *Stream) Close() error {
 		s.closed = true
 		err := newError(C.Pa_CloseStream(s.paStream))
 		delStream(s)
+		for _, f := range s.onClose {
+			f()
+		}
 		return err
 	}
 	return nil
@@ -889,11 +1012,38 @@ func streamCallback(inputBuffer, outputBuffer unsafe.Pointer, frames C.ulong, ti
 	}()
 
 	s := getStream(uintptr(userData))
-	s.timeInfo = StreamCallbackTimeInfo{duration(timeInfo.inputBufferAdcTime), duration(timeInfo.currentTime), duration(timeInfo.outputBufferDacTime)}
+	s.timeInfo = StreamCallbackTimeInfo{
+		InputBufferAdcTime:  duration(timeInfo.inputBufferAdcTime),
+		CurrentTime:         duration(timeInfo.currentTime),
+		OutputBufferDacTime: duration(timeInfo.outputBufferDacTime),
+		InputFrame:          atomic.LoadInt64(&s.inFrames),
+		OutputFrame:         atomic.LoadInt64(&s.outFrames),
+	}
 	s.flags = StreamCallbackFlags(statusFlags)
-	updateBuffer(s.in, uintptr(inputBuffer), s.inParams, int(frames))
-	updateBuffer(s.out, uintptr(outputBuffer), s.outParams, int(frames))
-	s.callback.Call(s.args)
+	n := int(frames)
+	if a := s.adapter; a != nil {
+		n = a.callback(s, inputBuffer, outputBuffer, n)
+	} else {
+		updateBuffer(s.in, uintptr(inputBuffer), s.inParams, n)
+		updateBuffer(s.out, uintptr(outputBuffer), s.outParams, n)
+		if c := s.inConv; c != nil && inputBuffer != nil {
+			c.alloc(c.user, n)
+			c.in()
+		}
+		if c := s.outConv; c != nil && outputBuffer != nil {
+			c.alloc(c.user, n)
+		}
+		s.callback.Call(s.args)
+		if c := s.outConv; c != nil && outputBuffer != nil {
+			c.out()
+		}
+	}
+	if inputBuffer != nil {
+		atomic.AddInt64(&s.inFrames, int64(n))
+	}
+	if outputBuffer != nil {
+		atomic.AddInt64(&s.outFrames, int64(n))
+	}
 }
 
 func updateBuffer(buf *reflect.SliceHeader, p uintptr, params *C.PaStreamParameters, frames int) {
@@ -991,17 +1141,37 @@ func (s *Stream) AvailableToWrite() (int, error) {
 // Read uses the buffer provided to OpenStream.
 // The number of samples to read is determined by the size of the buffer.
 func (s *Stream) Read() error {
+	_, err := s.read()
+	return err
+}
+
+// read is like Read but also returns the number of frames read.
+func (s *Stream) read() (int, error) {
 	if s.callback.IsValid() {
-		return CanNotReadFromACallbackStream
+		return 0, CanNotReadFromACallbackStream
 	}
 	if s.in == nil {
-		return CanNotReadFromAnOutputOnlyStream
+		return 0, CanNotReadFromAnOutputOnlyStream
+	}
+	if c := s.inConv; c != nil {
+		frames, err := c.userFrames(s.inParams)
+		if err != nil {
+			return 0, err
+		}
+		c.alloc(c.native, frames)
 	}
 	buf, frames, err := getBuffer(s.in, s.inParams)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	err = newError(C.Pa_ReadStream(s.paStream, buf, C.ulong(frames)))
+	if err == nil || err == InputOverflowed {
+		atomic.AddInt64(&s.inFrames, int64(frames))
 	}
-	return newError(C.Pa_ReadStream(s.paStream, buf, C.ulong(frames)))
+	if s.inConv != nil {
+		s.inConv.in()
+	}
+	return frames, err
 }
 
 // Write uses the buffer provided to OpenStream.
@@ -1013,11 +1183,23 @@ func (s *Stream) Write() error {
 	if s.out == nil {
 		return CanNotWriteToAnInputOnlyStream
 	}
+	if c := s.outConv; c != nil {
+		frames, err := c.userFrames(s.outParams)
+		if err != nil {
+			return err
+		}
+		c.alloc(c.native, frames)
+		c.out()
+	}
 	buf, frames, err := getBuffer(s.out, s.outParams)
 	if err != nil {
 		return err
 	}
-	return newError(C.Pa_WriteStream(s.paStream, buf, C.ulong(frames)))
+	err = newError(C.Pa_WriteStream(s.paStream, buf, C.ulong(frames)))
+	if err == nil || err == OutputUnderflowed {
+		atomic.AddInt64(&s.outFrames, int64(frames))
+	}
+	return err
 }
 
 func getBuffer(s *reflect.SliceHeader, p *C.PaStreamParameters) (unsafe.Pointer, int, error) {
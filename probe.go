@@ -0,0 +1,149 @@
+package portaudio
+
+/*
+#include <portaudio.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Capabilities reports the stream formats that a device supports, as probed by ProbeCapabilities.
+// It contains no pointers, so it may be cached, e.g., encoded as JSON and keyed by device and host API name.
+type Capabilities struct {
+	Device            string
+	HostApi           string
+	DefaultSampleRate float64
+
+	// Input, Output, and Duplex list the supported formats of input-only, output-only,
+	// and full-duplex streams on the device.  A duplex format has the same number of input and output channels.
+	Input, Output, Duplex Formats
+}
+
+// A Format is a combination of stream parameters.
+type Format struct {
+	SampleRate     float64
+	Channels       int
+	SampleType     string // the Buffer sample type, e.g., "float32", "int16", or "portaudio.Int24"
+	NonInterleaved bool
+}
+
+// Formats is a list of Formats.
+type Formats []Format
+
+// Supports reports whether f is in fs.
+func (fs Formats) Supports(f Format) bool {
+	for _, g := range fs {
+		if g == f {
+			return true
+		}
+	}
+	return false
+}
+
+// SampleRates returns the distinct sample rates of fs, in increasing order.
+func (fs Formats) SampleRates() []float64 {
+	var rates []float64
+	for _, f := range fs {
+		if !contains(rates, f.SampleRate) {
+			rates = append(rates, f.SampleRate)
+		}
+	}
+	sort.Float64s(rates)
+	return rates
+}
+
+// Channels returns the distinct channel counts of fs, in increasing order.
+func (fs Formats) Channels() []int {
+	var channels []int
+	for _, f := range fs {
+		if !contains(channels, f.Channels) {
+			channels = append(channels, f.Channels)
+		}
+	}
+	sort.Ints(channels)
+	return channels
+}
+
+// probeSampleFormats are the sample formats probed by ProbeCapabilities.
+var probeSampleFormats = []C.PaSampleFormat{C.paFloat32, C.paInt32, C.paInt24, C.paInt16, C.paInt8, C.paUInt8}
+
+// ProbeCapabilities asks PortAudio (with IsFormatSupported) which formats dev supports:
+// every combination of the common sample rates from 8 kHz to 192 kHz and dev.DefaultSampleRate,
+// all sample formats, interleaved and non-interleaved, and channel counts of 1, 2, 4, 6, 8,
+// and the device's maximum.  Latencies are the device's default high latencies.
+//
+// Probing may take some time, because some host APIs open the device for each query.
+func ProbeCapabilities(dev *DeviceInfo) (*Capabilities, error) {
+	if initialized <= 0 {
+		return nil, NotInitialized
+	}
+	if dev == nil {
+		return nil, fmt.Errorf("portaudio: nil device")
+	}
+	c := &Capabilities{Device: dev.Name, DefaultSampleRate: dev.DefaultSampleRate}
+	if dev.HostApi != nil {
+		c.HostApi = dev.HostApi.Name
+	}
+	rates := append([]float64(nil), commonSampleRates...)
+	if dev.DefaultSampleRate > 0 && !contains(rates, dev.DefaultSampleRate) {
+		rates = append(rates, dev.DefaultSampleRate)
+		sort.Float64s(rates)
+	}
+	params := func(channels int, f C.PaSampleFormat, latency C.PaTime) *C.PaStreamParameters {
+		return &C.PaStreamParameters{
+			device:           C.int(dev.Index),
+			channelCount:     C.int(channels),
+			sampleFormat:     f,
+			suggestedLatency: latency,
+		}
+	}
+	inLatency := C.PaTime(dev.DefaultHighInputLatency.Seconds())
+	outLatency := C.PaTime(dev.DefaultHighOutputLatency.Seconds())
+	maxDuplex := dev.MaxInputChannels
+	if dev.MaxOutputChannels < maxDuplex {
+		maxDuplex = dev.MaxOutputChannels
+	}
+	for _, rate := range rates {
+		for _, f := range probeSampleFormats {
+			for _, nonInterleaved := range []bool{false, true} {
+				f := f
+				if nonInterleaved {
+					f |= C.paNonInterleaved
+				}
+				format := func(channels int) Format {
+					return Format{rate, channels, sampleType(f).String(), nonInterleaved}
+				}
+				for _, ch := range probeChannels(dev.MaxInputChannels) {
+					if C.Pa_IsFormatSupported(params(ch, f, inLatency), nil, C.double(rate)) == C.paNoError {
+						c.Input = append(c.Input, format(ch))
+					}
+				}
+				for _, ch := range probeChannels(dev.MaxOutputChannels) {
+					if C.Pa_IsFormatSupported(nil, params(ch, f, outLatency), C.double(rate)) == C.paNoError {
+						c.Output = append(c.Output, format(ch))
+					}
+				}
+				for _, ch := range probeChannels(maxDuplex) {
+					if C.Pa_IsFormatSupported(params(ch, f, inLatency), params(ch, f, outLatency), C.double(rate)) == C.paNoError {
+						c.Duplex = append(c.Duplex, format(ch))
+					}
+				}
+			}
+		}
+	}
+	return c, nil
+}
+
+// probeChannels returns the channel counts to probe on a device with the given maximum.
+func probeChannels(max int) []int {
+	var channels []int
+	for _, c := range []int{1, 2, 4, 6, 8, max} {
+		if c > 0 && c <= max && !contains(channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	return channels
+}
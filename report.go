@@ -0,0 +1,172 @@
+package portaudio
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// A SystemReport describes the PortAudio installation and its host APIs and devices, for diagnostics.
+// It refers to devices by ID, so it may be encoded as JSON, e.g., to attach to a bug report or
+// to compare machines.  Its String method formats it for people.
+type SystemReport struct {
+	Version     int
+	VersionText string
+
+	// DefaultHostApi is the name of the default host API.  DefaultInputDevice and DefaultOutputDevice
+	// are the IDs of the default devices (see DeviceInfo.ID), or empty if there are none.
+	DefaultHostApi                          string
+	DefaultInputDevice, DefaultOutputDevice string
+
+	HostApis []HostApiReport
+	Devices  []DeviceReport
+}
+
+// A HostApiReport describes a host API in a SystemReport.  Devices are referred to by ID.
+type HostApiReport struct {
+	Type                                    string
+	Name                                    string
+	DefaultInputDevice, DefaultOutputDevice string
+	Devices                                 []string
+}
+
+// A DeviceReport describes a device in a SystemReport.
+type DeviceReport struct {
+	ID                       string
+	Index                    int
+	Name                     string
+	HostApi                  string
+	MaxInputChannels         int
+	MaxOutputChannels        int
+	DefaultLowInputLatency   time.Duration
+	DefaultLowOutputLatency  time.Duration
+	DefaultHighInputLatency  time.Duration
+	DefaultHighOutputLatency time.Duration
+	DefaultSampleRate        float64
+
+	// Capabilities are the device's supported formats, as probed by ProbeCapabilities.
+	Capabilities *Capabilities
+}
+
+// Report returns a SystemReport of the host APIs and devices, with each device's capabilities.
+// Because it probes every device, it may take some time.
+func Report() (*SystemReport, error) {
+	hosts, devs, err := hostsAndDevices()
+	if err != nil {
+		return nil, err
+	}
+	r := &SystemReport{Version: Version(), VersionText: VersionText()}
+	if len(hosts) > 0 {
+		if h, err := DefaultHostApi(); err == nil {
+			r.DefaultHostApi = h.Name
+		}
+	}
+	if len(devs) > 0 {
+		if d, err := DefaultInputDevice(); err == nil {
+			r.DefaultInputDevice = d.ID
+		}
+		if d, err := DefaultOutputDevice(); err == nil {
+			r.DefaultOutputDevice = d.ID
+		}
+	}
+	for _, h := range hosts {
+		hr := HostApiReport{Type: h.Type.String(), Name: h.Name}
+		if h.DefaultInputDevice != nil {
+			hr.DefaultInputDevice = h.DefaultInputDevice.ID
+		}
+		if h.DefaultOutputDevice != nil {
+			hr.DefaultOutputDevice = h.DefaultOutputDevice.ID
+		}
+		for _, d := range h.Devices {
+			hr.Devices = append(hr.Devices, d.ID)
+		}
+		r.HostApis = append(r.HostApis, hr)
+	}
+	for _, d := range devs {
+		dr := DeviceReport{
+			ID:                       d.ID,
+			Index:                    d.Index,
+			Name:                     d.Name,
+			MaxInputChannels:         d.MaxInputChannels,
+			MaxOutputChannels:        d.MaxOutputChannels,
+			DefaultLowInputLatency:   d.DefaultLowInputLatency,
+			DefaultLowOutputLatency:  d.DefaultLowOutputLatency,
+			DefaultHighInputLatency:  d.DefaultHighInputLatency,
+			DefaultHighOutputLatency: d.DefaultHighOutputLatency,
+			DefaultSampleRate:        d.DefaultSampleRate,
+		}
+		if d.HostApi != nil {
+			dr.HostApi = d.HostApi.Name
+		}
+		if dr.Capabilities, err = ProbeCapabilities(d); err != nil {
+			return nil, err
+		}
+		r.Devices = append(r.Devices, dr)
+	}
+	return r, nil
+}
+
+// String formats r as indented text.  Capabilities are summarized by their
+// sample rates, channel counts, and sample types.
+func (r *SystemReport) String() string {
+	var b strings.Builder
+	p := func(indent int, format string, args ...interface{}) {
+		b.WriteString(strings.Repeat("\t", indent))
+		fmt.Fprintf(&b, format, args...)
+		b.WriteByte('\n')
+	}
+	p(0, "PortAudio %s (%d)", r.VersionText, r.Version)
+	p(0, "Default host API:       %s", r.DefaultHostApi)
+	p(0, "Default input device:   %s", r.DefaultInputDevice)
+	p(0, "Default output device:  %s", r.DefaultOutputDevice)
+	p(0, "%d host APIs:", len(r.HostApis))
+	for _, h := range r.HostApis {
+		p(1, "%s (%s)", h.Name, h.Type)
+		p(2, "Default input device:   %s", h.DefaultInputDevice)
+		p(2, "Default output device:  %s", h.DefaultOutputDevice)
+		p(2, "%d devices", len(h.Devices))
+	}
+	p(0, "%d devices:", len(r.Devices))
+	for _, d := range r.Devices {
+		p(1, "%d: %s", d.Index, d.ID)
+		p(2, "Name:                      %s", d.Name)
+		p(2, "HostApi:                   %s", d.HostApi)
+		p(2, "MaxInputChannels:          %d", d.MaxInputChannels)
+		p(2, "MaxOutputChannels:         %d", d.MaxOutputChannels)
+		p(2, "DefaultLowInputLatency:    %v", d.DefaultLowInputLatency)
+		p(2, "DefaultLowOutputLatency:   %v", d.DefaultLowOutputLatency)
+		p(2, "DefaultHighInputLatency:   %v", d.DefaultHighInputLatency)
+		p(2, "DefaultHighOutputLatency:  %v", d.DefaultHighOutputLatency)
+		p(2, "DefaultSampleRate:         %v", d.DefaultSampleRate)
+		if c := d.Capabilities; c != nil {
+			for _, f := range []struct {
+				name string
+				fs   Formats
+			}{{"Input", c.Input}, {"Output", c.Output}, {"Duplex", c.Duplex}} {
+				if len(f.fs) == 0 {
+					continue
+				}
+				p(2, "%s formats:", f.name)
+				p(3, "Sample rates:  %v", f.fs.SampleRates())
+				p(3, "Channels:      %v", f.fs.Channels())
+				p(3, "Sample types:  %s", strings.Join(sampleTypes(f.fs), " "))
+			}
+		}
+	}
+	return b.String()
+}
+
+// sampleTypes returns the distinct sample types of fs, in order of appearance.
+func sampleTypes(fs Formats) []string {
+	var types []string
+	for _, f := range fs {
+		t := f.SampleType
+		if f.NonInterleaved {
+			t = "[]" + t
+		}
+		if !contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return types
+}
@@ -0,0 +1,317 @@
+/*
+Package resample converts the sample rate of streams of float32 audio samples.
+
+A Resampler converts by an arbitrary ratio, which may vary over time, e.g., to track
+the drift between two devices' clocks.  It interpolates either linearly, which is cheap
+but aliases, or with a Kaiser-windowed sinc filter, whose length is chosen by its Quality.
+When downsampling, the filter's cutoff is lowered to avoid aliasing.
+
+Buffers are interleaved ([]float32) or non-interleaved ([][]float32), as for portaudio Buffers.
+Process does not allocate, so it may be called from a portaudio StreamCallback.
+*/
+package resample
+
+import "math"
+
+// Quality selects the interpolation used by a Resampler.
+type Quality int
+
+const (
+	// Linear interpolates linearly between adjacent frames.  It has a latency of one frame
+	// and little cost, but it does not filter, so it aliases.
+	Linear Quality = iota + 1
+
+	// Low, Medium, and High use windowed-sinc filters with 8, 16, and 32 zero crossings
+	// on each side, passbands of 86%, 91%, and 95% of the Nyquist frequency,
+	// and stopband attenuations of about 60, 80, and 100 dB, respectively.
+	Low
+	Medium
+	High
+)
+
+var filters = map[Quality]struct {
+	taps          int
+	beta, rolloff float64
+}{
+	Low:    {8, 6, .86},
+	Medium: {16, 8.5, .91},
+	High:   {32, 10.5, .95},
+}
+
+// phases is the number of filter table entries per zero crossing.
+const phases = 512
+
+// A Resampler converts a stream of frames from one sample rate to another.
+//
+// A Resampler should be used for only one stream at a time.
+type Resampler struct {
+	channels int
+	quality  Quality
+	taps     int
+	rolloff  float64
+	table    []float64 // one side of the windowed sinc, at intervals of 1/phases
+
+	ratio, step float64 // output frames per input frame, and its inverse
+	cutoff      float64 // relative to the input Nyquist frequency
+	width       int     // input frames on either side of an output frame that contribute to it
+
+	hist  []float32 // buffered input frames, interleaved
+	pos   float64   // position in hist of the next output frame
+	skip  int       // input frames to discard before buffering more
+	frame []float32 // the next output frame
+}
+
+// New returns a Resampler for a stream with the given number of channels
+// that produces ratio output frames per input frame.
+// A zero Quality selects Medium.
+func New(channels int, ratio float64, q Quality) *Resampler {
+	if channels < 1 {
+		channels = 1
+	}
+	if q == 0 {
+		q = Medium
+	}
+	r := &Resampler{channels: channels, quality: q, frame: make([]float32, channels)}
+	if f, ok := filters[q]; ok {
+		r.taps = f.taps
+		r.rolloff = f.rolloff
+		r.table = make([]float64, f.taps*phases+2)
+		for i := range r.table[:f.taps*phases+1] {
+			x := float64(i) / phases
+			r.table[i] = sinc(x) * kaiser(x/float64(f.taps), f.beta)
+		}
+	} else if q != Linear {
+		panic("resample: invalid Quality")
+	}
+	r.SetRatio(ratio)
+	r.Reset()
+	return r
+}
+
+// Channels returns the number of channels of r.
+func (r *Resampler) Channels() int {
+	return r.channels
+}
+
+// Ratio returns the number of output frames per input frame.
+func (r *Resampler) Ratio() float64 {
+	return r.ratio
+}
+
+// SetRatio sets the number of output frames per input frame, which must be positive.
+// It may be called between calls to Process, e.g., to vary the ratio smoothly.
+// It allocates only if the ratio is lower than any before, which widens the filter.
+func (r *Resampler) SetRatio(ratio float64) {
+	if !(ratio > 0) || math.IsInf(ratio, 0) {
+		panic("resample: invalid ratio")
+	}
+	r.ratio = ratio
+	r.step = 1 / ratio
+	if r.quality == Linear {
+		r.width = 1
+	} else {
+		r.cutoff = r.rolloff * math.Min(1, ratio)
+		r.width = int(math.Ceil(float64(r.taps) / r.cutoff))
+	}
+	if n := (4*r.width + 1024) * r.channels; cap(r.hist) < n {
+		hist := make([]float32, len(r.hist), n)
+		copy(hist, r.hist)
+		r.hist = hist
+	}
+}
+
+// Latency returns the number of input frames that r buffers ahead of the output frame
+// it produces next.  The output itself is not delayed:  output frame n corresponds to
+// input frame n/Ratio (for a constant ratio), with the first input frame at frame 0.
+func (r *Resampler) Latency() int {
+	return r.width
+}
+
+// Reset discards all buffered input, as if r were new.
+func (r *Resampler) Reset() {
+	r.hist = r.hist[:r.width*r.channels]
+	for i := range r.hist {
+		r.hist[i] = 0
+	}
+	r.pos = float64(r.width)
+	r.skip = 0
+}
+
+// InputFrames returns the number of input frames that Process needs in order to
+// produce the given number of output frames at the current ratio.
+func (r *Resampler) InputFrames(outFrames int) int {
+	if outFrames <= 0 {
+		return 0
+	}
+	last := int(r.pos + float64(outFrames-1)*r.step)
+	n := last + r.width + 1 - r.frames() + r.skip
+	if n < 0 {
+		return 0
+	}
+	return n
+}
+
+// OutputFrames returns the number of output frames that Process produces
+// from the given number of input frames at the current ratio, if given room.
+func (r *Resampler) OutputFrames(inFrames int) int {
+	avail := float64(r.frames()+inFrames-r.skip-r.width) - r.pos
+	if avail <= 0 {
+		return 0
+	}
+	return int(math.Ceil(avail / r.step))
+}
+
+// Process resamples the interleaved frames of src into dst, both of which have r.Channels() channels.
+// It returns the number of frames read from src and written to dst.  It stops when src is exhausted
+// or dst is full; frames that it does not read should be passed to the next call.
+func (r *Resampler) Process(dst, src []float32) (read, written int) {
+	ch := r.channels
+	return r.process(len(src)/ch, len(dst)/ch, func(hist []float32, from, n int) {
+		copy(hist, src[from*ch:(from+n)*ch])
+	}, func(k int, frame []float32) {
+		copy(dst[k*ch:], frame)
+	})
+}
+
+// ProcessNonInterleaved is like Process for non-interleaved buffers, each of which has r.Channels() channels.
+func (r *Resampler) ProcessNonInterleaved(dst, src [][]float32) (read, written int) {
+	ch := r.channels
+	return r.process(shortest(src, ch), shortest(dst, ch), func(hist []float32, from, n int) {
+		for c, s := range src[:ch] {
+			for i, x := range s[from : from+n] {
+				hist[i*ch+c] = x
+			}
+		}
+	}, func(k int, frame []float32) {
+		for c, d := range dst[:ch] {
+			d[k] = frame[c]
+		}
+	})
+}
+
+func shortest(buf [][]float32, channels int) int {
+	if len(buf) < channels {
+		panic("resample: too few channels")
+	}
+	n := math.MaxInt32
+	for _, b := range buf[:channels] {
+		if len(b) < n {
+			n = len(b)
+		}
+	}
+	return n
+}
+
+// process alternately produces output frames by calling emit, and buffers input frames by calling fill.
+func (r *Resampler) process(inFrames, outFrames int, fill func(hist []float32, from, n int), emit func(k int, frame []float32)) (read, written int) {
+	ch := r.channels
+	for {
+		for written < outFrames && int(r.pos)+r.width < r.frames() {
+			r.interpolate()
+			emit(written, r.frame)
+			written++
+			r.pos += r.step
+		}
+		if written == outFrames || read == inFrames {
+			return read, written
+		}
+		r.compact()
+		if r.skip > 0 {
+			n := r.skip
+			if n > inFrames-read {
+				n = inFrames - read
+			}
+			read += n
+			r.skip -= n
+			continue
+		}
+		n := (cap(r.hist) - len(r.hist)) / ch
+		if n > inFrames-read {
+			n = inFrames - read
+		}
+		fill(r.hist[len(r.hist):len(r.hist)+n*ch], read, n)
+		r.hist = r.hist[:len(r.hist)+n*ch]
+		read += n
+	}
+}
+
+func (r *Resampler) frames() int {
+	return len(r.hist) / r.channels
+}
+
+// compact discards the buffered frames that no future output frame depends on.
+func (r *Resampler) compact() {
+	drop := int(r.pos) - r.width + 1
+	if drop <= 0 {
+		return
+	}
+	r.pos -= float64(drop)
+	if n := r.frames(); drop > n {
+		r.skip += drop - n
+		drop = n
+	}
+	r.hist = r.hist[:copy(r.hist, r.hist[drop*r.channels:])]
+}
+
+// interpolate computes the output frame at pos.
+func (r *Resampler) interpolate() {
+	ch := r.channels
+	i := int(r.pos)
+	if r.quality == Linear {
+		t := float32(r.pos - float64(i))
+		a, b := r.hist[i*ch:], r.hist[(i+1)*ch:]
+		for c := range r.frame {
+			r.frame[c] = a[c] + t*(b[c]-a[c])
+		}
+		return
+	}
+	for c := range r.frame {
+		r.frame[c] = 0
+	}
+	j := i - r.width + 1
+	if j < 0 {
+		j = 0 // only after the ratio has decreased
+	}
+	for ; j <= i+r.width; j++ {
+		x := math.Abs(float64(j)-r.pos) * r.cutoff
+		if x >= float64(r.taps) {
+			continue
+		}
+		w := float32(r.cutoff * r.kernel(x))
+		s := r.hist[j*ch:]
+		for c := range r.frame {
+			r.frame[c] += w * s[c]
+		}
+	}
+}
+
+// kernel returns the windowed sinc at x, 0 <= x < r.taps.
+func (r *Resampler) kernel(x float64) float64 {
+	f := x * phases
+	i := int(f)
+	t := f - float64(i)
+	return r.table[i] + t*(r.table[i+1]-r.table[i])
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// kaiser returns the Kaiser window at x, -1 <= x <= 1.
+func kaiser(x, beta float64) float64 {
+	return besselI0(beta*math.Sqrt(1-x*x)) / besselI0(beta)
+}
+
+// besselI0 returns the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; term > sum*1e-12; k++ {
+		term *= (x / 2 / float64(k)) * (x / 2 / float64(k))
+		sum += term
+	}
+	return sum
+}
@@ -0,0 +1,106 @@
+package portaudio
+
+import "sync/atomic"
+
+// A RingBuffer is a lock-free, single-producer/single-consumer FIFO queue
+// in the manner of PortAudio's PaUtilRingBuffer.  It is intended to pass
+// samples between a StreamCallback and other goroutines without blocking or
+// allocating on the audio thread.
+//
+// The element type T may be a sample type, or a frame type such as [2]float32.
+//
+// At any time, one goroutine may write (Write, WriteRegions, CommitWrite)
+// while another reads (Read, ReadRegions, CommitRead, Flush).
+// AvailableToRead and AvailableToWrite may be called by either.
+type RingBuffer[T any] struct {
+	// Free-running indices; accessed atomically, so they must stay 64-bit aligned.
+	write, read uint64
+	buf         []T
+	mask        uint64
+}
+
+// NewRingBuffer returns a RingBuffer holding up to size elements,
+// where size is rounded up to a power of two.
+func NewRingBuffer[T any](size int) *RingBuffer[T] {
+	n := 1
+	for n < size {
+		n <<= 1
+	}
+	return &RingBuffer[T]{buf: make([]T, n), mask: uint64(n - 1)}
+}
+
+// Cap returns the number of elements r can hold.
+func (r *RingBuffer[T]) Cap() int {
+	return len(r.buf)
+}
+
+// AvailableToRead returns the number of elements that can be read without waiting.
+func (r *RingBuffer[T]) AvailableToRead() int {
+	return int(atomic.LoadUint64(&r.write) - atomic.LoadUint64(&r.read))
+}
+
+// AvailableToWrite returns the number of elements that can be written without overwriting unread elements.
+func (r *RingBuffer[T]) AvailableToWrite() int {
+	return len(r.buf) - r.AvailableToRead()
+}
+
+// WriteRegions returns up to n writable elements of r in two regions:
+// a, then b if the space wraps around the end of the buffer.
+// After filling them, call CommitWrite.
+func (r *RingBuffer[T]) WriteRegions(n int) (a, b []T) {
+	if avail := r.AvailableToWrite(); n > avail {
+		n = avail
+	}
+	return r.regions(atomic.LoadUint64(&r.write), n)
+}
+
+// CommitWrite makes n elements available for reading, after they have been written to the regions returned by WriteRegions.
+func (r *RingBuffer[T]) CommitWrite(n int) {
+	atomic.StoreUint64(&r.write, atomic.LoadUint64(&r.write)+uint64(n))
+}
+
+// ReadRegions returns up to n readable elements of r in two regions:
+// a, then b if the data wraps around the end of the buffer.
+// After consuming them, call CommitRead.
+func (r *RingBuffer[T]) ReadRegions(n int) (a, b []T) {
+	if avail := r.AvailableToRead(); n > avail {
+		n = avail
+	}
+	return r.regions(atomic.LoadUint64(&r.read), n)
+}
+
+// CommitRead releases n elements for writing, after they have been read from the regions returned by ReadRegions.
+func (r *RingBuffer[T]) CommitRead(n int) {
+	atomic.StoreUint64(&r.read, atomic.LoadUint64(&r.read)+uint64(n))
+}
+
+func (r *RingBuffer[T]) regions(index uint64, n int) (a, b []T) {
+	i := int(index & r.mask)
+	if i+n <= len(r.buf) {
+		return r.buf[i : i+n], nil
+	}
+	return r.buf[i:], r.buf[:i+n-len(r.buf)]
+}
+
+// Write writes as many elements of p as fit and returns the number written.
+func (r *RingBuffer[T]) Write(p []T) int {
+	a, b := r.WriteRegions(len(p))
+	n := copy(a, p)
+	n += copy(b, p[n:])
+	r.CommitWrite(n)
+	return n
+}
+
+// Read reads up to len(p) elements into p and returns the number read.
+func (r *RingBuffer[T]) Read(p []T) int {
+	a, b := r.ReadRegions(len(p))
+	n := copy(p, a)
+	n += copy(p[n:], b)
+	r.CommitRead(n)
+	return n
+}
+
+// Flush discards all unread elements.  It must only be called by the reader.
+func (r *RingBuffer[T]) Flush() {
+	atomic.StoreUint64(&r.read, atomic.LoadUint64(&r.write))
+}
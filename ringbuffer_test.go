@@ -0,0 +1,146 @@
+package portaudio
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestNewRingBufferSize(t *testing.T) {
+	for _, test := range []struct{ size, cap int }{
+		{-1, 1}, {0, 1}, {1, 1}, {2, 2}, {3, 4}, {5, 8}, {1000, 1024}, {1024, 1024}, {1025, 2048},
+	} {
+		r := NewRingBuffer[int](test.size)
+		if r.Cap() != test.cap {
+			t.Errorf("NewRingBuffer(%d).Cap() = %d, want %d", test.size, r.Cap(), test.cap)
+		}
+		if r.AvailableToRead() != 0 || r.AvailableToWrite() != test.cap {
+			t.Errorf("NewRingBuffer(%d): %d available to read and %d to write, want 0 and %d", test.size, r.AvailableToRead(), r.AvailableToWrite(), test.cap)
+		}
+	}
+}
+
+func TestRingBufferWraparound(t *testing.T) {
+	r := NewRingBuffer[int](8)
+	next, want := 0, 0
+	for i := 0; i < 10; i++ {
+		// Write 5 and read 5 so that the regions start at every offset and often wrap.
+		a, b := r.WriteRegions(5)
+		if len(a)+len(b) != 5 {
+			t.Fatalf("WriteRegions(5) returned %d and %d elements", len(a), len(b))
+		}
+		if wraps := (next&7)+5 > 8; wraps != (len(b) > 0) {
+			t.Errorf("write at %d: regions of %d and %d elements", next&7, len(a), len(b))
+		}
+		for _, p := range [][]int{a, b} {
+			for j := range p {
+				p[j] = next
+				next++
+			}
+		}
+		r.CommitWrite(5)
+		if r.AvailableToRead() != 5 || r.AvailableToWrite() != 3 {
+			t.Fatalf("%d available to read and %d to write, want 5 and 3", r.AvailableToRead(), r.AvailableToWrite())
+		}
+		if a, b := r.WriteRegions(5); len(a)+len(b) != 3 {
+			t.Errorf("WriteRegions(5) on a ring with 3 free returned %d elements", len(a)+len(b))
+		}
+
+		a, b = r.ReadRegions(8)
+		if len(a)+len(b) != 5 {
+			t.Fatalf("ReadRegions(8) returned %d and %d elements, want 5", len(a), len(b))
+		}
+		for _, p := range [][]int{a, b} {
+			for _, x := range p {
+				if x != want {
+					t.Fatalf("read %d, want %d", x, want)
+				}
+				want++
+			}
+		}
+		r.CommitRead(5)
+	}
+}
+
+func TestRingBufferReadWrite(t *testing.T) {
+	r := NewRingBuffer[int](4)
+	if n := r.Write([]int{1, 2, 3, 4, 5}); n != 4 {
+		t.Errorf("Write of 5 elements to a ring of 4 wrote %d", n)
+	}
+	p := make([]int, 3)
+	if n := r.Read(p); n != 3 || p[0] != 1 || p[2] != 3 {
+		t.Errorf("Read returned %d elements %v, want 3 elements [1 2 3]", n, p[:n])
+	}
+	if n := r.Write([]int{5, 6, 7, 8}); n != 3 {
+		t.Errorf("Write of 4 elements to a ring with 3 free wrote %d", n)
+	}
+	if n := r.Read(p); n != 3 || p[0] != 4 || p[2] != 6 {
+		t.Errorf("Read returned %d elements %v, want 3 elements [4 5 6]", n, p[:n])
+	}
+	if n := r.Read(p); n != 1 || p[0] != 7 {
+		t.Errorf("Read returned %d elements %v, want 1 element [7]", n, p[:n])
+	}
+	if n := r.Read(p); n != 0 {
+		t.Errorf("Read of an empty ring returned %d elements", n)
+	}
+}
+
+func TestRingBufferFlush(t *testing.T) {
+	r := NewRingBuffer[int](4)
+	r.Write([]int{1, 2, 3})
+	r.Flush()
+	if r.AvailableToRead() != 0 || r.AvailableToWrite() != 4 {
+		t.Errorf("after Flush, %d available to read and %d to write, want 0 and 4", r.AvailableToRead(), r.AvailableToWrite())
+	}
+	r.Write([]int{4, 5, 6})
+	p := make([]int, 4)
+	if n := r.Read(p); n != 3 || p[0] != 4 || p[2] != 6 {
+		t.Errorf("after Flush, Read returned %d elements %v, want 3 elements [4 5 6]", n, p[:n])
+	}
+}
+
+// TestRingBufferConcurrent passes a sequence through a small ring between two goroutines.
+// Run it with -race.
+func TestRingBufferConcurrent(t *testing.T) {
+	const total = 100000
+	r := NewRingBuffer[[2]int](16)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		next := 0
+		for next < total {
+			a, b := r.WriteRegions(7)
+			n := 0
+			for _, p := range [][][2]int{a, b} {
+				for j := range p {
+					if next+n < total {
+						p[j] = [2]int{next + n, -(next + n)}
+						n++
+					}
+				}
+			}
+			r.CommitWrite(n)
+			next += n
+			if n == 0 {
+				runtime.Gosched()
+			}
+		}
+	}()
+
+	p := make([][2]int, 5)
+	for want := 0; want < total; {
+		n := r.Read(p)
+		for _, x := range p[:n] {
+			if x != [2]int{want, -want} {
+				t.Fatalf("read %v, want %v", x, [2]int{want, -want})
+			}
+			want++
+		}
+		if n == 0 {
+			runtime.Gosched()
+		}
+	}
+	<-done
+	if n := r.AvailableToRead(); n != 0 {
+		t.Errorf("%d elements left over", n)
+	}
+}
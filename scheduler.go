@@ -0,0 +1,126 @@
+package portaudio
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrSchedulerFull is returned when an event is scheduled on a Scheduler that has no room for it.
+var ErrSchedulerFull = errors.New("portaudio: scheduler full")
+
+// An EventFunc renders a scheduled event.  It writes (or, typically, mixes) frames of the event
+// into out, an interleaved output buffer whose first frame is frame pos of the event,
+// where frame 0 is the frame at which the event was scheduled.
+// It returns false once the event has finished.
+type EventFunc[T any] func(out []T, pos int64) bool
+
+// A Scheduler renders events with sample accuracy in an output stream.
+//
+// Events may be scheduled from any goroutine, keyed either by frame position
+// (see StreamCallbackTimeInfo.OutputFrame) or by stream time (see
+// StreamCallbackTimeInfo.OutputBufferDacTime).  They are passed to the stream callback
+// through a RingBuffer, so Render never blocks or allocates.
+type Scheduler[T any] struct {
+	mu         sync.Mutex // serializes producers of queue
+	queue      *RingBuffer[scheduledEvent[T]]
+	events     []scheduledEvent[T] // owned by Render
+	sampleRate float64
+	channels   int
+}
+
+type scheduledEvent[T any] struct {
+	f      EventFunc[T]
+	frame  int64
+	t      time.Duration
+	byTime bool
+}
+
+// NewScheduler returns a Scheduler for an interleaved output stream
+// that can hold up to capacity pending and playing events.
+func NewScheduler[T any](sampleRate float64, channels, capacity int) (*Scheduler[T], error) {
+	switch {
+	case !(sampleRate > 0):
+		return nil, fmt.Errorf("portaudio: invalid sample rate %v", sampleRate)
+	case channels < 1:
+		return nil, fmt.Errorf("portaudio: invalid number of channels %d", channels)
+	case capacity < 1:
+		return nil, fmt.Errorf("portaudio: invalid scheduler capacity %d", capacity)
+	}
+	return &Scheduler[T]{
+		queue:      NewRingBuffer[scheduledEvent[T]](capacity),
+		events:     make([]scheduledEvent[T], 0, capacity),
+		sampleRate: sampleRate,
+		channels:   channels,
+	}, nil
+}
+
+// ScheduleFrame schedules f to start at the given output frame position.
+func (s *Scheduler[T]) ScheduleFrame(frame int64, f EventFunc[T]) error {
+	return s.schedule(scheduledEvent[T]{f: f, frame: frame})
+}
+
+// ScheduleTime schedules f to start at the given stream time, in the same
+// time base as StreamCallbackTimeInfo.OutputBufferDacTime and Stream.Time.
+func (s *Scheduler[T]) ScheduleTime(t time.Duration, f EventFunc[T]) error {
+	return s.schedule(scheduledEvent[T]{f: f, t: t, byTime: true})
+}
+
+func (s *Scheduler[T]) schedule(e scheduledEvent[T]) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, _ := s.queue.WriteRegions(1)
+	if len(a) == 0 {
+		return ErrSchedulerFull
+	}
+	a[0] = e
+	s.queue.CommitWrite(1)
+	return nil
+}
+
+// Render renders all events that fall within out, the interleaved output buffer
+// of a stream callback with the given timeInfo.  It must be called from the
+// stream callback, once per callback, after out has been filled (or cleared)
+// so that events can mix into it.
+//
+// An event scheduled for a frame that has already passed starts immediately,
+// but from the position it would have reached had it started on time.
+func (s *Scheduler[T]) Render(out []T, timeInfo StreamCallbackTimeInfo) {
+	for len(s.events) < cap(s.events) {
+		a, _ := s.queue.ReadRegions(1)
+		if len(a) == 0 {
+			break
+		}
+		e := a[0]
+		a[0] = scheduledEvent[T]{} // don't retain f
+		s.queue.CommitRead(1)
+		if e.byTime {
+			e.frame = TimeFrame(e.t, timeInfo.OutputBufferDacTime, timeInfo.OutputFrame, s.sampleRate)
+		}
+		s.events = append(s.events, e)
+	}
+
+	start := timeInfo.OutputFrame
+	end := start + int64(len(out)/s.channels)
+	for i := 0; i < len(s.events); {
+		e := s.events[i]
+		if e.frame >= end {
+			i++
+			continue
+		}
+		offset := e.frame - start
+		if offset < 0 {
+			offset = 0
+		}
+		if e.f(out[offset*int64(s.channels):], start+offset-e.frame) {
+			i++
+			continue
+		}
+		// Remove finished event.
+		last := len(s.events) - 1
+		s.events[i] = s.events[last]
+		s.events[last] = scheduledEvent[T]{}
+		s.events = s.events[:last]
+	}
+}
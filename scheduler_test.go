@@ -0,0 +1,41 @@
+package portaudio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewSchedulerInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name               string
+		sampleRate         float64
+		channels, capacity int
+	}{
+		{"zero sample rate", 0, 2, 8},
+		{"negative sample rate", -44100, 2, 8},
+		{"NaN sample rate", math.NaN(), 2, 8},
+		{"zero channels", 44100, 0, 8},
+		{"negative channels", 44100, -1, 8},
+		{"zero capacity", 44100, 2, 0},
+		{"negative capacity", 44100, 2, -1},
+	} {
+		s, err := NewScheduler[float32](test.sampleRate, test.channels, test.capacity)
+		if err == nil || s != nil {
+			t.Errorf("%s: NewScheduler(%v, %d, %d) = %v, %v, want an error", test.name, test.sampleRate, test.channels, test.capacity, s, err)
+		}
+	}
+}
+
+func TestNewScheduler(t *testing.T) {
+	s, err := NewScheduler[float32](44100, 2, 1)
+	if err != nil {
+		t.Fatalf("NewScheduler(44100, 2, 1): %v", err)
+	}
+	f := func(out []float32, pos int64) bool { return false }
+	if err := s.ScheduleFrame(0, f); err != nil {
+		t.Errorf("ScheduleFrame on an empty Scheduler: %v", err)
+	}
+	if err := s.ScheduleFrame(0, f); err != ErrSchedulerFull {
+		t.Errorf("ScheduleFrame on a full Scheduler returned %v, want ErrSchedulerFull", err)
+	}
+}
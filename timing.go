@@ -0,0 +1,62 @@
+package portaudio
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// InputPosition returns the number of frames that have been read from the stream,
+// or passed to its callback as input, since it was opened.
+func (s *Stream) InputPosition() int64 {
+	return atomic.LoadInt64(&s.inFrames)
+}
+
+// OutputPosition returns the number of frames that have been written to the stream,
+// or requested from its callback as output, since it was opened.
+func (s *Stream) OutputPosition() int64 {
+	return atomic.LoadInt64(&s.outFrames)
+}
+
+// FramesToDuration returns the duration of the given number of frames at the sample rate.
+func FramesToDuration(frames int64, sampleRate float64) time.Duration {
+	return time.Duration(math.Round(float64(frames) / sampleRate * float64(time.Second)))
+}
+
+// DurationToFrames returns the number of frames, rounded to the nearest, in d at the sample rate.
+func DurationToFrames(d time.Duration, sampleRate float64) int64 {
+	return int64(math.Round(d.Seconds() * sampleRate))
+}
+
+// FrameTime returns the stream time of frame, given that frame refFrame occurs at refTime.
+// Typically, refFrame and refTime are the OutputFrame and OutputBufferDacTime
+// (or InputFrame and InputBufferAdcTime) of a StreamCallbackTimeInfo.
+func FrameTime(frame, refFrame int64, refTime time.Duration, sampleRate float64) time.Duration {
+	return refTime + FramesToDuration(frame-refFrame, sampleRate)
+}
+
+// TimeFrame returns the frame, rounded to the nearest, that occurs at stream time t,
+// given that frame refFrame occurs at refTime.  It is the inverse of FrameTime.
+func TimeFrame(t, refTime time.Duration, refFrame int64, sampleRate float64) int64 {
+	return refFrame + DurationToFrames(t-refTime, sampleRate)
+}
+
+// WallTime returns the wall-clock time corresponding to the stream time t (see Time).
+// The two clocks are compared on each call, so the result is only as accurate as
+// the scheduling of the calling goroutine.
+func (s *Stream) WallTime(t time.Duration) time.Time {
+	now, streamNow := time.Now(), s.Time()
+	return now.Add(t - streamNow)
+}
+
+// StreamTime returns the stream time (see Time) corresponding to the wall-clock time t.
+// It is the inverse of WallTime.
+func (s *Stream) StreamTime(t time.Time) time.Duration {
+	now, streamNow := time.Now(), s.Time()
+	return streamNow + t.Sub(now)
+}
+
+// outChannels returns the number of output channels of s.
+func (s *Stream) outChannels() int {
+	return int(s.outParams.channelCount)
+}
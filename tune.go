@@ -0,0 +1,185 @@
+package portaudio
+
+import (
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// TuneOptions configure Tune.  Zero fields take their defaults.
+type TuneOptions struct {
+	// MaxFramesPerBuffer and MinFramesPerBuffer bound the buffer sizes tried, which are powers of two
+	// from the largest down.  The defaults are 2048 and 32.
+	MaxFramesPerBuffer, MinFramesPerBuffer int
+
+	// Duration is how long each buffer size is tried.  The default is 3 seconds.
+	Duration time.Duration
+
+	// MaxCpuLoad is the highest acceptable CPU load (see Stream.CpuLoad).  The default is 0.8.
+	MaxCpuLoad float64
+}
+
+// A TuneTrial reports the behavior of a test stream with one buffer size.
+type TuneTrial struct {
+	FramesPerBuffer int
+	Callbacks       int
+	Underflows      int // callbacks flagged InputUnderflow or OutputUnderflow
+	Overflows       int // callbacks flagged InputOverflow or OutputOverflow
+
+	// MaxInterval is the longest wall-clock time between callbacks.  It is for information only:
+	// some host APIs call back in bursts, so long intervals do not imply instability.
+	MaxInterval time.Duration
+
+	// MinMargin is the least time by which a callback preceded the output time of its buffer
+	// (OutputBufferDacTime - CurrentTime), or zero for an input-only stream or if the host API
+	// does not report the output time.  Late counts the callbacks without a positive margin.
+	MinMargin time.Duration
+	Late      int
+
+	CpuLoad float64
+	Stable  bool
+}
+
+// A TuneResult is the outcome of Tune.  It contains no pointers,
+// so it may be cached, e.g., encoded as JSON and keyed by Key.
+type TuneResult struct {
+	// Key identifies the devices, channels, and sample rate that were tuned; see TuneKey.
+	Key string
+
+	// FramesPerBuffer and the latencies are the recommended values, those of the smallest stable
+	// buffer size.  They are zero if no buffer size was stable.
+	FramesPerBuffer             int
+	InputLatency, OutputLatency time.Duration
+
+	Trials []TuneTrial
+}
+
+// TuneKey returns a key identifying the devices (by ID), channels, and sample rate of p, for caching TuneResults.
+func TuneKey(p StreamParameters) string {
+	var in, out string
+	if p.Input.Device != nil {
+		in = p.Input.Device.ID
+	}
+	if p.Output.Device != nil {
+		out = p.Output.Device.ID
+	}
+	return fmt.Sprintf("%s/%d|%s/%d|%g", in, p.Input.Channels, out, p.Output.Channels, p.SampleRate)
+}
+
+// Apply returns p with the recommended buffer size and latencies, or p unchanged if there are none.
+func (r *TuneResult) Apply(p StreamParameters) StreamParameters {
+	if r.FramesPerBuffer == 0 {
+		return p
+	}
+	p.FramesPerBuffer = r.FramesPerBuffer
+	if p.Input.Device != nil {
+		p.Input.Latency = r.InputLatency
+	}
+	if p.Output.Device != nil {
+		p.Output.Latency = r.OutputLatency
+	}
+	return p
+}
+
+// Tune finds the smallest stable buffer size for the devices, channels, and sample rate of p.
+// It opens a test stream, which passes silence, at decreasing buffer sizes, each with latencies of
+// twice the buffer duration, and watches the callbacks for underflows, overflows, output that is late
+// for the DAC, and high CPU load.  It stops at the first unstable buffer size.
+//
+// Tuning takes several seconds and should be done while the system is under its typical load.
+func Tune(p StreamParameters, opts TuneOptions) (*TuneResult, error) {
+	if opts.MaxFramesPerBuffer == 0 {
+		opts.MaxFramesPerBuffer = 2048
+	}
+	if opts.MinFramesPerBuffer == 0 {
+		opts.MinFramesPerBuffer = 32
+	}
+	if opts.Duration == 0 {
+		opts.Duration = 3 * time.Second
+	}
+	if opts.MaxCpuLoad == 0 {
+		opts.MaxCpuLoad = .8
+	}
+	r := &TuneResult{Key: TuneKey(p)}
+	for n := opts.MaxFramesPerBuffer; n >= opts.MinFramesPerBuffer; n /= 2 {
+		q := p
+		q.FramesPerBuffer = n
+		latency := FramesToDuration(2*int64(n), p.SampleRate)
+		q.Input.Latency, q.Output.Latency = latency, latency
+		t, err := tuneTrial(q, opts)
+		if err != nil {
+			if len(r.Trials) == 0 {
+				return nil, err
+			}
+			break
+		}
+		r.Trials = append(r.Trials, t)
+		if !t.Stable {
+			break
+		}
+		r.FramesPerBuffer = n
+		r.InputLatency, r.OutputLatency = latency, latency
+	}
+	return r, nil
+}
+
+func tuneTrial(p StreamParameters, opts TuneOptions) (TuneTrial, error) {
+	t := TuneTrial{FramesPerBuffer: p.FramesPerBuffer}
+	// Updated by the callback and read atomically.
+	var last, maxInterval, callbacks, underflows, overflows, late int64
+	minMargin := int64(math.MaxInt64)
+	start := time.Now()
+	warmup := opts.Duration / 10
+	s, err := OpenStream(p, func(in, out []float32, timeInfo StreamCallbackTimeInfo, flags StreamCallbackFlags) {
+		for i := range out {
+			out[i] = 0
+		}
+		now := int64(time.Since(start))
+		prev := atomic.SwapInt64(&last, now)
+		if time.Duration(now) < warmup {
+			return
+		}
+		atomic.AddInt64(&callbacks, 1)
+		if d := now - prev; d > atomic.LoadInt64(&maxInterval) {
+			atomic.StoreInt64(&maxInterval, d)
+		}
+		if timeInfo.OutputBufferDacTime != 0 {
+			margin := int64(timeInfo.OutputBufferDacTime - timeInfo.CurrentTime)
+			if margin < atomic.LoadInt64(&minMargin) {
+				atomic.StoreInt64(&minMargin, margin)
+			}
+			if margin <= 0 {
+				atomic.AddInt64(&late, 1)
+			}
+		}
+		if flags&(InputUnderflow|OutputUnderflow) != 0 {
+			atomic.AddInt64(&underflows, 1)
+		}
+		if flags&(InputOverflow|OutputOverflow) != 0 {
+			atomic.AddInt64(&overflows, 1)
+		}
+	})
+	if err != nil {
+		return t, err
+	}
+	defer s.Close()
+	if err := s.Start(); err != nil {
+		return t, err
+	}
+	time.Sleep(opts.Duration)
+	t.CpuLoad = s.CpuLoad()
+	if err := s.Stop(); err != nil {
+		return t, err
+	}
+	t.Callbacks = int(atomic.LoadInt64(&callbacks))
+	t.Underflows = int(atomic.LoadInt64(&underflows))
+	t.Overflows = int(atomic.LoadInt64(&overflows))
+	t.Late = int(atomic.LoadInt64(&late))
+	t.MaxInterval = time.Duration(atomic.LoadInt64(&maxInterval))
+	if m := atomic.LoadInt64(&minMargin); m != math.MaxInt64 {
+		t.MinMargin = time.Duration(m)
+	}
+	t.Stable = t.Callbacks > 0 && t.Underflows == 0 && t.Overflows == 0 && t.Late == 0 && t.CpuLoad <= opts.MaxCpuLoad
+	return t, nil
+}
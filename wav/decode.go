@@ -0,0 +1,139 @@
+package wav
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/convert"
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// A Decoder reads samples from a WAV file.
+type Decoder struct {
+	format Format
+	frames int64
+	data   pcm.Reader
+}
+
+// NewDecoder reads the header of the WAV file in r, up to the start of its sample data,
+// and returns a Decoder for the samples.  Chunks other than the format and data chunks are skipped.
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, eofToFormatError(err)
+	}
+	if string(riff[:4]) != "RIFF" || string(riff[8:]) != "WAVE" {
+		return nil, ErrFormat
+	}
+	d := &Decoder{data: pcm.Reader{R: r}}
+	haveFormat := false
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			return nil, eofToFormatError(err)
+		}
+		id, size := string(hdr[:4]), binary.LittleEndian.Uint32(hdr[4:])
+		switch id {
+		case "fmt ":
+			if size < 16 || size > 1<<16 {
+				return nil, ErrFormat
+			}
+			b := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return nil, eofToFormatError(err)
+			}
+			if err := d.format.parse(b[:size]); err != nil {
+				return nil, err
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return nil, fmt.Errorf("wav: data chunk precedes format chunk")
+			}
+			d.data.Codec = d.format.codec()
+			d.frames, d.data.Remaining = -1, -1
+			if size != unknownSize {
+				d.frames = int64(size) / int64(d.data.Codec.FrameSize())
+				d.data.Remaining = d.frames * int64(d.data.Codec.FrameSize())
+			}
+			return d, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, eofToFormatError(err)
+			}
+		}
+	}
+}
+
+func eofToFormatError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFormat
+	}
+	return err
+}
+
+// parse parses the body of a format chunk.
+func (f *Format) parse(b []byte) error {
+	le := binary.LittleEndian
+	tag := le.Uint16(b)
+	f.Channels = int(le.Uint16(b[2:]))
+	f.SampleRate = int(le.Uint32(b[4:]))
+	blockAlign := int(le.Uint16(b[12:]))
+	f.BitsPerSample = int(le.Uint16(b[14:]))
+	if tag == formatExtensible {
+		if len(b) < 40 || int(le.Uint16(b[16:])) < 22 || string(b[26:40]) != guidTail {
+			return ErrFormat
+		}
+		f.Extensible = true
+		f.ValidBitsPerSample = int(le.Uint16(b[18:]))
+		f.ChannelMask = ChannelMask(le.Uint32(b[20:]))
+		tag = le.Uint16(b[24:])
+		if f.ValidBitsPerSample == f.BitsPerSample {
+			f.ValidBitsPerSample = 0
+		}
+	}
+	switch tag {
+	case formatPCM:
+	case formatFloat:
+		f.Float = true
+	default:
+		return fmt.Errorf("wav: unsupported format tag %#x", tag)
+	}
+	if err := f.validate(); err != nil {
+		return err
+	}
+	if blockAlign != f.Channels*f.BitsPerSample/8 {
+		return fmt.Errorf("wav: unsupported block alignment %d for %d %d-bit channels", blockAlign, f.Channels, f.BitsPerSample)
+	}
+	return nil
+}
+
+// Format returns the format of the file.
+func (d *Decoder) Format() Format {
+	return d.format
+}
+
+// Frames returns the number of frames in the file, or -1 if the header does not say,
+// as in a file that was streamed.
+func (d *Decoder) Frames() int64 {
+	return d.frames
+}
+
+// SetConvertOptions sets the options for quantizing samples decoded into a narrower sample type.
+// By default they are truncated.
+func (d *Decoder) SetConvertOptions(opts convert.Options) {
+	d.data.Codec.Converter = convert.NewConverter(d.format.Channels, opts)
+}
+
+// Read decodes as many frames as fit into buf (see the package comment) and returns the number read.
+// At the end of the samples, it returns 0 and io.EOF.  If the file ends before the end
+// given by its header, Read returns io.ErrUnexpectedEOF.
+func (d *Decoder) Read(buf portaudio.Buffer) (int, error) {
+	n, err := d.data.Codec.BufferFrames(buf)
+	if err != nil {
+		return 0, fmt.Errorf("wav: %v", err)
+	}
+	return d.data.Read(buf, n)
+}
@@ -0,0 +1,173 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gordonklaus/portaudio"
+	"github.com/gordonklaus/portaudio/convert"
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// ErrTooLarge is returned by Encoder.Write when the sample data would exceed the 4 GiB limit of a WAV file.
+var ErrTooLarge = errors.New("wav: file too large")
+
+// An Encoder writes samples to a WAV file.
+//
+// The header's sizes are written as unknown, as for a stream, until Close writes them
+// if the underlying writer is an io.WriteSeeker.
+type Encoder struct {
+	w      io.Writer
+	format Format
+	codec  pcm.Codec
+
+	// factOffset is the offset of the fact chunk's frame count, or 0 if there is no fact chunk.
+	factOffset int64
+	headerSize int64
+	dataSize   int64
+	buf        []byte
+	err        error
+}
+
+// NewEncoder writes the header of a WAV file with format f to w and returns an Encoder for its samples.
+func NewEncoder(w io.Writer, f Format) (*Encoder, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	e := &Encoder{w: w, format: f, codec: f.codec()}
+	le := binary.LittleEndian
+	tag := uint16(formatPCM)
+	if f.Float {
+		tag = formatFloat
+	}
+	fmtChunk := make([]byte, 16, 40)
+	le.PutUint16(fmtChunk, tag)
+	le.PutUint16(fmtChunk[2:], uint16(f.Channels))
+	le.PutUint32(fmtChunk[4:], uint32(f.SampleRate))
+	le.PutUint32(fmtChunk[8:], uint32(f.SampleRate*e.codec.FrameSize()))
+	le.PutUint16(fmtChunk[12:], uint16(e.codec.FrameSize()))
+	le.PutUint16(fmtChunk[14:], uint16(f.BitsPerSample))
+	if f.extensible() {
+		le.PutUint16(fmtChunk, formatExtensible)
+		validBits := f.ValidBitsPerSample
+		if validBits == 0 {
+			validBits = f.BitsPerSample
+		}
+		fmtChunk = fmtChunk[:40]
+		le.PutUint16(fmtChunk[16:], 22)
+		le.PutUint16(fmtChunk[18:], uint16(validBits))
+		le.PutUint32(fmtChunk[20:], uint32(f.ChannelMask))
+		le.PutUint16(fmtChunk[24:], tag)
+		copy(fmtChunk[26:], guidTail)
+	} else if f.Float {
+		fmtChunk = fmtChunk[:18] // with a zero cbSize
+	}
+
+	var hdr []byte
+	chunk := func(id string, body []byte) {
+		var h [8]byte
+		copy(h[:], id)
+		le.PutUint32(h[4:], uint32(len(body)))
+		hdr = append(append(hdr, h[:]...), body...)
+	}
+	chunk("RIFF", nil)
+	hdr = append(hdr, "WAVE"...)
+	chunk("fmt ", fmtChunk)
+	if f.Float || f.extensible() {
+		// Formats other than plain PCM require a fact chunk with the number of frames.
+		e.factOffset = int64(len(hdr)) + 8
+		chunk("fact", make([]byte, 4))
+	}
+	chunk("data", nil)
+	le.PutUint32(hdr[4:], unknownSize)
+	le.PutUint32(hdr[len(hdr)-4:], unknownSize)
+	e.headerSize = int64(len(hdr))
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Format returns the format of the file.
+func (e *Encoder) Format() Format {
+	return e.format
+}
+
+// Frames returns the number of frames written.
+func (e *Encoder) Frames() int64 {
+	return e.dataSize / int64(e.codec.FrameSize())
+}
+
+// SetConvertOptions sets the options for quantizing samples encoded into a narrower sample type.
+// By default they are truncated.
+func (e *Encoder) SetConvertOptions(opts convert.Options) {
+	e.codec.Converter = convert.NewConverter(e.format.Channels, opts)
+}
+
+// Write encodes the frames of buf (see the package comment) and returns the number written.
+func (e *Encoder) Write(buf portaudio.Buffer) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.codec.BufferFrames(buf)
+	if err != nil {
+		return 0, fmt.Errorf("wav: %v", err)
+	}
+	size := int64(n * e.codec.FrameSize())
+	if e.headerSize-8+e.dataSize+size+1 >= unknownSize {
+		return 0, ErrTooLarge
+	}
+	e.buf = e.codec.Encode(e.buf[:0], buf, n)
+	m, err := e.w.Write(e.buf)
+	e.dataSize += int64(m)
+	if err != nil {
+		e.err = err
+		return m / e.codec.FrameSize(), err
+	}
+	return n, nil
+}
+
+// Close finishes the file:  it pads the sample data to an even length, as RIFF requires,
+// and writes the sizes in the header if the underlying writer is an io.WriteSeeker,
+// leaving it positioned at the end of the file.  It does not close the underlying writer.
+func (e *Encoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	e.err = errors.New("wav: encoder closed")
+	pad := e.dataSize % 2
+	if pad == 1 {
+		if _, err := e.w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	ws, ok := e.w.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	le := binary.LittleEndian
+	put := func(offset int64, x uint32) error {
+		if _, err := ws.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		var b [4]byte
+		le.PutUint32(b[:], x)
+		_, err := ws.Write(b[:])
+		return err
+	}
+	if err := put(4, uint32(e.headerSize-8+e.dataSize+pad)); err != nil {
+		return err
+	}
+	if e.factOffset != 0 {
+		if err := put(e.factOffset, uint32(e.Frames())); err != nil {
+			return err
+		}
+	}
+	if err := put(e.headerSize-4, uint32(e.dataSize)); err != nil {
+		return err
+	}
+	_, err := ws.Seek(e.headerSize+e.dataSize+pad, io.SeekStart)
+	return err
+}
@@ -0,0 +1,124 @@
+/*
+Package wav reads and writes WAV (RIFF WAVE) audio files.
+
+It supports integer PCM samples of 8, 16, 24, and 32 bits, IEEE float samples of 32 and 64 bits,
+and the WAVE_FORMAT_EXTENSIBLE header with its channel mask.  Files are read and written
+as streams:  a Decoder reads samples as they are needed, and an Encoder writes them as they are given
+and fixes up the header's sizes when it is closed.
+
+Samples are decoded into and encoded from portaudio Buffers, interleaved ([]T) or non-interleaved ([][]T),
+where T is float32, int32, portaudio.Int24, int16, int8, or uint8.  Each WAV sample size has a matching
+Buffer type, into which it decodes exactly:  8-bit samples, which WAV stores unsigned, into []uint8,
+and 24-bit samples into []portaudio.Int24.
+*/
+package wav
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gordonklaus/portaudio/internal/pcm"
+)
+
+// Format describes the samples of a WAV file.
+type Format struct {
+	SampleRate int
+	Channels   int
+
+	// BitsPerSample is 8, 16, 24, or 32 for integer samples, and 32 or 64 for Float samples.
+	// 8-bit samples are unsigned (offset binary); the others are signed.
+	BitsPerSample int
+	Float         bool
+
+	// Extensible selects the WAVE_FORMAT_EXTENSIBLE header.  The Encoder writes it also
+	// if ChannelMask or ValidBitsPerSample is nonzero or there are more than two channels.
+	Extensible bool
+
+	// ChannelMask assigns the channels, in order, to speaker positions.  Zero leaves them unassigned.
+	ChannelMask ChannelMask
+
+	// ValidBitsPerSample, if nonzero, is the number of significant bits of each sample,
+	// for a file whose samples are less precise than BitsPerSample.
+	ValidBitsPerSample int
+}
+
+// ChannelMask is a set of speaker positions, as in WAVE_FORMAT_EXTENSIBLE.
+type ChannelMask uint32
+
+// Speaker positions.
+const (
+	FrontLeft ChannelMask = 1 << iota
+	FrontRight
+	FrontCenter
+	LowFrequency
+	BackLeft
+	BackRight
+	FrontLeftOfCenter
+	FrontRightOfCenter
+	BackCenter
+	SideLeft
+	SideRight
+	TopCenter
+	TopFrontLeft
+	TopFrontCenter
+	TopFrontRight
+	TopBackLeft
+	TopBackCenter
+	TopBackRight
+)
+
+// Common channel masks.
+const (
+	Mono       = FrontCenter
+	Stereo     = FrontLeft | FrontRight
+	Quad       = FrontLeft | FrontRight | BackLeft | BackRight
+	Surround51 = FrontLeft | FrontRight | FrontCenter | LowFrequency | BackLeft | BackRight
+	Surround71 = Surround51 | SideLeft | SideRight
+)
+
+// Format tags.
+const (
+	formatPCM        = 1
+	formatFloat      = 3
+	formatExtensible = 0xfffe
+)
+
+// guidTail is the last 14 bytes of the KSDATAFORMAT_SUBTYPE GUIDs of the extensible format,
+// whose first 2 bytes are a format tag.
+const guidTail = "\x00\x00\x00\x00\x10\x00\x80\x00\x00\xaa\x00\x38\x9b\x71"
+
+// unknownSize is the size of a chunk whose writer did not know its size, e.g., when streaming.
+const unknownSize = 0xffffffff
+
+// ErrFormat is returned when reading a file that is not a valid WAV file.
+var ErrFormat = errors.New("wav: invalid format")
+
+func (f Format) validate() error {
+	switch {
+	case f.SampleRate <= 0:
+		return fmt.Errorf("wav: invalid sample rate %d", f.SampleRate)
+	case f.Channels < 1 || f.Channels > 0xffff:
+		return fmt.Errorf("wav: invalid number of channels %d", f.Channels)
+	case !f.encoding().Valid():
+		kind := "integer"
+		if f.Float {
+			kind = "float"
+		}
+		return fmt.Errorf("wav: unsupported %d-bit %s samples", f.BitsPerSample, kind)
+	case f.ValidBitsPerSample < 0 || f.ValidBitsPerSample > f.BitsPerSample:
+		return fmt.Errorf("wav: invalid number of valid bits per sample %d", f.ValidBitsPerSample)
+	}
+	return nil
+}
+
+func (f Format) extensible() bool {
+	return f.Extensible || f.ChannelMask != 0 || f.ValidBitsPerSample != 0 || f.Channels > 2
+}
+
+func (f Format) encoding() pcm.Encoding {
+	return pcm.Encoding{Bits: f.BitsPerSample, Float: f.Float}
+}
+
+func (f Format) codec() pcm.Codec {
+	return pcm.Codec{Encoding: f.encoding(), Channels: f.Channels}
+}